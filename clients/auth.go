@@ -0,0 +1,63 @@
+package clients
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// validate checks that cfg names one endpoint, one auth mode and
+// consistent TLS settings.
+func (cfg *ClientConfig) validate() error {
+	switch {
+	case len(cfg.Addresses) == 0 && cfg.CloudID == "":
+		return errors.New("elasticsearch: no addresses or cloud ID provided")
+	case len(cfg.Addresses) > 0 && cfg.CloudID != "":
+		return errors.New("elasticsearch: both addresses and cloud ID provided")
+	}
+
+	var modes []string
+	if cfg.Username != "" || cfg.Password != "" {
+		if cfg.Username == "" || cfg.Password == "" {
+			return errors.New("elasticsearch: basic auth needs both username and password")
+		}
+		modes = append(modes, "basic")
+	}
+	if cfg.APIKey != "" {
+		modes = append(modes, "api key")
+	}
+	if cfg.ServiceToken != "" {
+		modes = append(modes, "service token")
+	}
+	switch len(modes) {
+	case 0:
+		return errors.New("elasticsearch: no auth configured, set username/password, API key or service token")
+	case 1:
+	default:
+		return fmt.Errorf("elasticsearch: several auth modes configured (%s), set exactly one", strings.Join(modes, ", "))
+	}
+
+	if cfg.CertificateFingerprint != "" {
+		// Accept the colon-separated form printed by openssl as well.
+		fp := strings.ToLower(strings.ReplaceAll(cfg.CertificateFingerprint, ":", ""))
+		if b, err := hex.DecodeString(fp); err != nil || len(b) != 32 {
+			return errors.New("elasticsearch: certificate fingerprint must be a hex SHA-256 digest")
+		}
+		cfg.CertificateFingerprint = fp
+
+		if cfg.TLS != nil || cfg.TLSConfig != nil {
+			return errors.New("elasticsearch: certificate fingerprint cannot be combined with TLS options or TLS config")
+		}
+	}
+
+	if cfg.TLS != nil {
+		if cfg.TLSConfig != nil {
+			return errors.New("elasticsearch: both TLS options and TLS config provided")
+		}
+		if err := cfg.TLS.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
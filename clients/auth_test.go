@@ -0,0 +1,54 @@
+package clients
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const fp = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name    string
+		cfg     ClientConfig
+		wantErr string
+	}{
+		{"basic auth", ClientConfig{Addresses: []string{"https://es:9200"}, Username: "u", Password: "p"}, ""},
+		{"no endpoint", ClientConfig{APIKey: "k"}, "no addresses or cloud ID"},
+		{"addresses and cloud ID", ClientConfig{Addresses: []string{"https://es:9200"}, CloudID: "c", APIKey: "k"}, "both addresses and cloud ID"},
+		{"no auth", ClientConfig{Addresses: []string{"https://es:9200"}}, "no auth configured"},
+		{"two auth modes", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", ServiceToken: "t"}, "several auth modes"},
+		{"username without password", ClientConfig{Addresses: []string{"https://es:9200"}, Username: "u"}, "both username and password"},
+		{"fingerprint", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", CertificateFingerprint: fp}, ""},
+		{"short fingerprint", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", CertificateFingerprint: "0123"}, "hex SHA-256"},
+		{"fingerprint and TLS options", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", CertificateFingerprint: fp, TLS: &TLSOptions{CAFile: "ca.pem"}}, "cannot be combined"},
+		{"fingerprint and TLS config", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", CertificateFingerprint: fp, TLSConfig: &tls.Config{}}, "cannot be combined"},
+		{"TLS options and TLS config", ClientConfig{Addresses: []string{"https://es:9200"}, APIKey: "k", TLS: &TLSOptions{}, TLSConfig: &tls.Config{}}, "both TLS options and TLS config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNormalisesFingerprint(t *testing.T) {
+	cfg := ClientConfig{
+		Addresses:              []string{"https://es:9200"},
+		APIKey:                 "k",
+		CertificateFingerprint: strings.Repeat("AB:", 31) + "AB",
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("ab", 32); cfg.CertificateFingerprint != want {
+		t.Errorf("fingerprint = %s, want %s", cfg.CertificateFingerprint, want)
+	}
+}
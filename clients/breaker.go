@@ -0,0 +1,284 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+)
+
+// ErrCircuitOpen is returned for requests rejected by an open CircuitBreaker.
+// It matches eserrors.ErrUnavailable.
+var ErrCircuitOpen error = circuitOpenError{}
+
+type circuitOpenError struct{}
+
+func (circuitOpenError) Error() string { return "elasticsearch: circuit breaker is open" }
+
+func (circuitOpenError) Is(target error) bool { return target == eserrors.ErrUnavailable }
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests pass, failures are counted
+	CircuitOpen                         // requests fail fast with ErrCircuitOpen
+	CircuitHalfOpen                     // a few probe requests pass
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig configures a CircuitBreaker. Zero values use the defaults.
+type CircuitBreakerConfig struct {
+	// FailureRatio opens the circuit once this fraction of the requests in
+	// the current window failed. Defaults to 0.5.
+	FailureRatio float64
+	// MinRequests is the number of requests in a window before FailureRatio
+	// applies. Defaults to 20.
+	MinRequests int
+	// Window is the period over which requests are counted. Defaults to 10s.
+	Window time.Duration
+	// OpenTimeout is how long the circuit stays open before probing.
+	// Defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe requests let through while
+	// half-open; the circuit closes once all of them succeed and opens again
+	// on the first failure. Defaults to 1.
+	HalfOpenRequests int
+
+	// IsFailure decides whether a round trip counts as a failure. By default
+	// transport errors other than a cancelled request context, 429 and 5xx
+	// responses are failures.
+	IsFailure func(res *http.Response, err error) bool
+	// OnStateChange is called after every state transition.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker fails requests fast while the cluster is failing, so callers
+// do not wait out the full timeout on every call. Set it as
+// ClientConfig.CircuitBreaker, or wrap a transport with WrapTransport or Wrap.
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64 // incremented on every transition
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	probes      int
+	successes   int
+}
+
+// NewCircuitBreaker returns a closed circuit breaker.
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isFailure
+	}
+	return &CircuitBreaker{cfg: cfg, windowStart: time.Now()}
+}
+
+// State returns the current state. An open circuit whose OpenTimeout has
+// passed is reported as half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// WrapTransport returns a transport passing requests to tp through the
+// breaker. It sits above the connection pool and retries of tp, so rejected
+// requests never mark a node as dead and a request counts once however
+// often it was retried.
+func (b *CircuitBreaker) WrapTransport(tp elastictransport.Interface) elastictransport.Interface {
+	return &breakerPerformer{breaker: b, next: tp}
+}
+
+// Wrap returns a RoundTripper passing requests to rt through the breaker.
+// Below elastic-transport every rejection is reported to the connection
+// pool as a node failure; prefer WrapTransport for Elasticsearch clients.
+func (b *CircuitBreaker) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &breakerTransport{breaker: b, next: rt}
+}
+
+type breakerTransport struct {
+	breaker *CircuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.breaker.do(func() (*http.Response, error) { return t.next.RoundTrip(req) })
+}
+
+// breakerPerformer wraps an elastictransport.Interface, forwarding the
+// optional interfaces the client type-asserts on.
+type breakerPerformer struct {
+	breaker *CircuitBreaker
+	next    elastictransport.Interface
+}
+
+func (t *breakerPerformer) Perform(req *http.Request) (*http.Response, error) {
+	return t.breaker.do(func() (*http.Response, error) { return t.next.Perform(req) })
+}
+
+func (t *breakerPerformer) InstrumentationEnabled() elastictransport.Instrumentation {
+	if tp, ok := t.next.(elastictransport.Instrumented); ok {
+		return tp.InstrumentationEnabled()
+	}
+	return nil
+}
+
+func (t *breakerPerformer) Metrics() (elastictransport.Metrics, error) {
+	if tp, ok := t.next.(elastictransport.Measurable); ok {
+		return tp.Metrics()
+	}
+	return elastictransport.Metrics{}, errors.New("transport is missing method Metrics()")
+}
+
+func (t *breakerPerformer) DiscoverNodes() error {
+	if tp, ok := t.next.(elastictransport.Discoverable); ok {
+		return tp.DiscoverNodes()
+	}
+	return errors.New("transport is missing method DiscoverNodes()")
+}
+
+// do runs a round trip through the breaker.
+func (b *CircuitBreaker) do(roundTrip func() (*http.Response, error)) (*http.Response, error) {
+	generation, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	res, err := roundTrip()
+	b.record(generation, b.cfg.IsFailure(res, err))
+	return res, err
+}
+
+// allow admits a request or returns ErrCircuitOpen. The returned generation
+// is passed to record so late results of an earlier state are ignored.
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	var from, to CircuitState
+	defer func() {
+		b.mu.Unlock()
+		b.notify(from, to)
+	}()
+
+	now := time.Now()
+	switch b.state {
+	case CircuitClosed:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return 0, ErrCircuitOpen
+		}
+		from, to = b.transition(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+func (b *CircuitBreaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	var from, to CircuitState
+	defer func() {
+		b.mu.Unlock()
+		b.notify(from, to)
+	}()
+
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case CircuitClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+			from, to = b.transition(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			from, to = b.transition(CircuitOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			from, to = b.transition(CircuitClosed)
+		}
+	}
+}
+
+// transition switches to state and resets the counters. b.mu must be held.
+func (b *CircuitBreaker) transition(state CircuitState) (from, to CircuitState) {
+	from = b.state
+	b.state = state
+	b.generation++
+
+	now := time.Now()
+	b.windowStart, b.requests, b.failures = now, 0, 0
+	b.probes, b.successes = 0, 0
+	if state == CircuitOpen {
+		b.openedAt = now
+	}
+	return from, state
+}
+
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if from == to {
+		return
+	}
+	metrics.Get().SetCircuitState(to.String())
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
+
+func isFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
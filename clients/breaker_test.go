@@ -0,0 +1,160 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+)
+
+// fakeTransport answers every request with status and counts the calls.
+type fakeTransport struct {
+	status int
+	calls  int
+}
+
+func (t *fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.calls++
+	return &http.Response{StatusCode: t.status, Body: http.NoBody}, nil
+}
+
+func (t *fakeTransport) Perform(req *http.Request) (*http.Response, error) {
+	return t.RoundTrip(req)
+}
+
+func newTestBreaker(transitions *[]string) *CircuitBreaker {
+	return NewCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      2,
+		Window:           time.Minute,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenRequests: 1,
+		OnStateChange: func(from, to CircuitState) {
+			*transitions = append(*transitions, from.String()+">"+to.String())
+		},
+	})
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	var transitions []string
+	b := newTestBreaker(&transitions)
+	next := &fakeTransport{status: http.StatusServiceUnavailable}
+	tp := b.WrapTransport(next)
+	req, _ := http.NewRequest(http.MethodGet, "http://es.local/", nil)
+
+	steps := []struct {
+		name      string
+		status    int
+		sleep     time.Duration
+		wantErr   error
+		wantCalls int
+		wantState CircuitState
+	}{
+		{"first failure stays closed", 503, 0, nil, 1, CircuitClosed},
+		{"failure ratio reached opens", 503, 0, nil, 2, CircuitOpen},
+		{"open rejects without calling next", 200, 0, ErrCircuitOpen, 2, CircuitOpen},
+		{"failed probe opens again", 503, 30 * time.Millisecond, nil, 3, CircuitOpen},
+		{"successful probe closes", 200, 30 * time.Millisecond, nil, 4, CircuitClosed},
+		{"closed passes", 200, 0, nil, 5, CircuitClosed},
+	}
+	for _, s := range steps {
+		time.Sleep(s.sleep)
+		next.status = s.status
+		_, err := tp.Perform(req)
+		if !errors.Is(err, s.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", s.name, err, s.wantErr)
+		}
+		if next.calls != s.wantCalls {
+			t.Fatalf("%s: next called %d times, want %d", s.name, next.calls, s.wantCalls)
+		}
+		if got := b.State(); got != s.wantState {
+			t.Fatalf("%s: state = %s, want %s", s.name, got, s.wantState)
+		}
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpenLimitsProbes(t *testing.T) {
+	var transitions []string
+	b := newTestBreaker(&transitions)
+	b.record(b.generation, true)
+	b.record(b.generation, true)
+	time.Sleep(30 * time.Millisecond)
+
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("state after OpenTimeout = %s, want half-open", got)
+	}
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("first probe rejected: %v", err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second probe error = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresLateResults(t *testing.T) {
+	var transitions []string
+	b := newTestBreaker(&transitions)
+
+	stale, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.record(b.generation, true)
+	b.record(b.generation, true)
+	time.Sleep(30 * time.Millisecond)
+	probe, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A success started while closed must not close the half-open circuit.
+	b.record(stale, false)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("state after late result = %s, want half-open", got)
+	}
+	b.record(probe, false)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("state after probe = %s, want closed", got)
+	}
+}
+
+func TestCircuitBreakerWrap(t *testing.T) {
+	var transitions []string
+	b := newTestBreaker(&transitions)
+	next := &fakeTransport{status: http.StatusTooManyRequests}
+	rt := b.Wrap(next)
+	req, _ := http.NewRequest(http.MethodGet, "http://es.local/", nil)
+
+	for i := 0; i < 3; i++ {
+		rt.RoundTrip(req)
+	}
+	if next.calls != 2 {
+		t.Errorf("next called %d times, want 2", next.calls)
+	}
+	if got := b.State(); got != CircuitOpen {
+		t.Errorf("state = %s, want open", got)
+	}
+}
+
+func TestErrCircuitOpenIsUnavailable(t *testing.T) {
+	err := fmt.Errorf("error getting response: %w", ErrCircuitOpen)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, eserrors.ErrUnavailable) {
+		t.Errorf("%v does not match ErrCircuitOpen and eserrors.ErrUnavailable", err)
+	}
+	if errors.Is(err, eserrors.ErrRejected) {
+		t.Errorf("%v matches eserrors.ErrRejected", err)
+	}
+}
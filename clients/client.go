@@ -3,56 +3,148 @@ package clients
 import (
 	"context"
 	"crypto/tls"
-	"errors"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
 	es "github.com/elastic/go-elasticsearch/v8"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ClientConfig holds ES connection config
+// ClientConfig holds ES connection config.
+// Exactly one of Addresses and CloudID, and exactly one auth mode
+// (Username/Password, APIKey or ServiceToken) must be set.
 type ClientConfig struct {
 	Addresses []string
-	Username  string
-	Password  string
-	Timeout   time.Duration
+	CloudID   string // Elastic Cloud deployment ID, instead of Addresses
+
+	Username     string
+	Password     string
+	APIKey       string // base64-encoded "id:api_key"
+	ServiceToken string // service account bearer token
+
+	// CertificateFingerprint is the hex SHA-256 fingerprint of the cluster
+	// CA certificate, as printed by Elasticsearch on first start, or of the
+	// server certificate itself. A pinned server certificate is trusted as
+	// is; a pinned CA must be sent in the server chain and the server
+	// certificate must verify against it for the dialed host name. It
+	// replaces the TLS setup, so TLS and TLSConfig must not be set with it.
+	CertificateFingerprint string
+
+	Timeout time.Duration
+	Retry   *RetryConfig // defaults to DefaultRetryConfig()
+
+	// Logger, if set, receives a debug record for every request, or a
+	// warning for failed ones. LogBodies adds the request and response
+	// bodies, which may contain personal data; use it for debugging only.
+	Logger    *slog.Logger
+	LogBodies bool
+
+	// TracerProvider, if set, receives an OpenTelemetry span for every
+	// request, as a child of the index or filter span in its context.
+	// TraceSearchBodies adds the query of search requests to the spans.
+	TracerProvider    trace.TracerProvider
+	TraceSearchBodies bool
+
+	// CircuitBreaker, if set, wraps every request to the cluster. Keep a
+	// reference to it to report its State.
+	CircuitBreaker *CircuitBreaker
+
+	// TLS configures CA, client certificates and reloading. TLSConfig is
+	// used as is instead; at most one of them may be set.
+	TLS       *TLSOptions
 	TLSConfig *tls.Config
 }
 
 // NewClient creates a new ES client with health check
 func NewClient(cfg ClientConfig) (*es.Client, error) {
-	if len(cfg.Addresses) == 0 {
-		return nil, errors.New("elasticsearch: no addresses provided")
+	return NewClientContext(context.Background(), cfg)
+}
+
+// NewClientContext creates a new ES client and pings it using ctx.
+// The ping is additionally bounded by cfg.Timeout.
+func NewClientContext(ctx context.Context, cfg ClientConfig) (*es.Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 30 * time.Second
 	}
+	retry := DefaultRetryConfig()
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		TLSClientConfig:       cfg.TLSConfig,
+		DialContext:           dialer.DialContext,
+		ResponseHeaderTimeout: cfg.Timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	}
+	if cfg.CertificateFingerprint != "" {
+		transport.DialTLSContext = fingerprintDialTLS(dialer, cfg.CertificateFingerprint)
+	}
+	if cfg.TLS != nil {
+		if cfg.TLS.ReloadInterval > 0 {
+			reloader, err := newTLSReloader(*cfg.TLS)
+			if err != nil {
+				return nil, err
+			}
+			transport.DialTLSContext = reloader.dialTLSContext(dialer)
+		} else {
+			tlsConfig, err := cfg.TLS.build()
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+	}
 
 	esCfg := es.Config{
-		Addresses: cfg.Addresses,
-		Username:  cfg.Username,
-		Password:  cfg.Password,
-		Transport: &http.Transport{
-			TLSClientConfig: cfg.TLSConfig,
-			DialContext: (&net.Dialer{
-				Timeout:   cfg.Timeout,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			ResponseHeaderTimeout: cfg.Timeout,
-			ExpectContinueTimeout: 1 * time.Second,
-			IdleConnTimeout:       90 * time.Second,
-			MaxIdleConns:          100,
+		Addresses:    cfg.Addresses,
+		CloudID:      cfg.CloudID,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		APIKey:       cfg.APIKey,
+		ServiceToken: cfg.ServiceToken,
+
+		MaxRetries:    retry.MaxRetries,
+		DisableRetry:  retry.MaxRetries <= 0,
+		RetryOnStatus: retry.RetryOnStatus,
+		RetryOnError:  retry.retryOnError,
+		RetryBackoff: func(attempt int) time.Duration {
+			metrics.Get().IncRetry("request")
+			return retry.Backoff(attempt)
 		},
+
+		Transport: transport,
+	}
+
+	if cfg.TracerProvider != nil {
+		esCfg.Instrumentation = es.NewOpenTelemetryInstrumentation(cfg.TracerProvider, cfg.TraceSearchBodies)
+	}
+	if cfg.Logger != nil {
+		esCfg.Logger = &slogLogger{logger: cfg.Logger, bodies: cfg.LogBodies}
 	}
 
 	client, err := es.NewClient(esCfg)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.CircuitBreaker != nil {
+		client.Transport = cfg.CircuitBreaker.WrapTransport(client.Transport)
+	}
 
 	// Health check with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
 	defer cancel()
 
 	if _, err := client.Ping(client.Ping.WithContext(ctx)); err != nil {
@@ -0,0 +1,268 @@
+package clients
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the serialised form of ClientConfig read by ConfigFromFile
+// and ConfigFromEnv. Secrets can be given inline or, preferably, as the path
+// of a file holding them, e.g. a mounted Kubernetes secret.
+type fileConfig struct {
+	Addresses []string `json:"addresses" yaml:"addresses"`
+	CloudID   string   `json:"cloud_id" yaml:"cloud_id"`
+
+	Username         string `json:"username" yaml:"username"`
+	Password         string `json:"password" yaml:"password"`
+	PasswordFile     string `json:"password_file" yaml:"password_file"`
+	APIKey           string `json:"api_key" yaml:"api_key"`
+	APIKeyFile       string `json:"api_key_file" yaml:"api_key_file"`
+	ServiceToken     string `json:"service_token" yaml:"service_token"`
+	ServiceTokenFile string `json:"service_token_file" yaml:"service_token_file"`
+
+	CertificateFingerprint string `json:"certificate_fingerprint" yaml:"certificate_fingerprint"`
+	CACert                 string `json:"ca_cert" yaml:"ca_cert"`                 // PEM CA bundle path
+	ClientCert             string `json:"client_cert" yaml:"client_cert"`         // PEM client certificate path
+	ClientKey              string `json:"client_key" yaml:"client_key"`           // PEM client key path
+	TLSMinVersion          string `json:"tls_min_version" yaml:"tls_min_version"` // "1.2" or "1.3"
+	TLSServerName          string `json:"tls_server_name" yaml:"tls_server_name"`
+	TLSReloadInterval      string `json:"tls_reload_interval" yaml:"tls_reload_interval"` // e.g. "1m"
+
+	Timeout string     `json:"timeout" yaml:"timeout"` // e.g. "10s"
+	Retry   *fileRetry `json:"retry" yaml:"retry"`
+}
+
+// fileRetry overrides fields of DefaultRetryConfig.
+type fileRetry struct {
+	MaxRetries    *int     `json:"max_retries" yaml:"max_retries"`
+	BackoffBase   string   `json:"backoff_base" yaml:"backoff_base"`
+	BackoffCap    string   `json:"backoff_cap" yaml:"backoff_cap"`
+	Jitter        *float64 `json:"jitter" yaml:"jitter"`
+	RetryOnStatus []int    `json:"retry_on_status" yaml:"retry_on_status"`
+}
+
+// ConfigFromFile reads a ClientConfig from a YAML (.yaml, .yml) or JSON
+// (.json) file. Keys are the snake_case names of the ClientConfig fields;
+// password, api_key and service_token also have a *_file variant naming a
+// file to read the secret from, and ca_cert, client_cert and client_key are
+// paths to PEM files.
+func ConfigFromFile(path string) (ClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClientConfig{}, fmt.Errorf("error reading client config: %w", err)
+	}
+
+	var fc fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fc)
+	case ".json":
+		err = json.Unmarshal(data, &fc)
+	default:
+		return ClientConfig{}, fmt.Errorf("unsupported client config format %q, want .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return ClientConfig{}, fmt.Errorf("error parsing client config %s: %w", path, err)
+	}
+	return fc.clientConfig()
+}
+
+// ConfigFromEnv reads a ClientConfig from the environment:
+//
+//	ES_ADDRESSES             comma-separated node URLs
+//	ES_CLOUD_ID              Elastic Cloud ID, instead of ES_ADDRESSES
+//	ES_USERNAME              basic auth user
+//	ES_PASSWORD[_FILE]       basic auth password, or a file holding it
+//	ES_API_KEY[_FILE]        API key, or a file holding it
+//	ES_SERVICE_TOKEN[_FILE]  service account token, or a file holding it
+//	ES_CERT_FINGERPRINT      hex SHA-256 fingerprint of the CA certificate
+//	ES_CA_CERT               PEM CA bundle path
+//	ES_CLIENT_CERT           PEM client certificate path for mutual TLS
+//	ES_CLIENT_KEY            PEM client key path for mutual TLS
+//	ES_TLS_MIN_VERSION       "1.2" or "1.3"
+//	ES_TLS_SERVER_NAME       host name verified against the server certificate
+//	ES_TLS_RELOAD_INTERVAL   how often certificate files are checked, e.g. "1m"
+//	ES_TIMEOUT               request timeout, e.g. "10s"
+//	ES_MAX_RETRIES           see RetryConfig
+//	ES_RETRY_BACKOFF_BASE    e.g. "100ms"
+//	ES_RETRY_BACKOFF_CAP     e.g. "5s"
+//	ES_RETRY_JITTER          0..1
+//	ES_RETRY_ON_STATUS       comma-separated status codes
+func ConfigFromEnv() (ClientConfig, error) {
+	fc := fileConfig{
+		Addresses:              splitList(os.Getenv("ES_ADDRESSES")),
+		CloudID:                os.Getenv("ES_CLOUD_ID"),
+		Username:               os.Getenv("ES_USERNAME"),
+		Password:               os.Getenv("ES_PASSWORD"),
+		PasswordFile:           os.Getenv("ES_PASSWORD_FILE"),
+		APIKey:                 os.Getenv("ES_API_KEY"),
+		APIKeyFile:             os.Getenv("ES_API_KEY_FILE"),
+		ServiceToken:           os.Getenv("ES_SERVICE_TOKEN"),
+		ServiceTokenFile:       os.Getenv("ES_SERVICE_TOKEN_FILE"),
+		CertificateFingerprint: os.Getenv("ES_CERT_FINGERPRINT"),
+		CACert:                 os.Getenv("ES_CA_CERT"),
+		ClientCert:             os.Getenv("ES_CLIENT_CERT"),
+		ClientKey:              os.Getenv("ES_CLIENT_KEY"),
+		TLSMinVersion:          os.Getenv("ES_TLS_MIN_VERSION"),
+		TLSServerName:          os.Getenv("ES_TLS_SERVER_NAME"),
+		TLSReloadInterval:      os.Getenv("ES_TLS_RELOAD_INTERVAL"),
+		Timeout:                os.Getenv("ES_TIMEOUT"),
+	}
+
+	retry := &fileRetry{
+		BackoffBase: os.Getenv("ES_RETRY_BACKOFF_BASE"),
+		BackoffCap:  os.Getenv("ES_RETRY_BACKOFF_CAP"),
+	}
+	set := retry.BackoffBase != "" || retry.BackoffCap != ""
+	if v := os.Getenv("ES_MAX_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("invalid ES_MAX_RETRIES %q: %w", v, err)
+		}
+		retry.MaxRetries, set = &n, true
+	}
+	if v := os.Getenv("ES_RETRY_JITTER"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("invalid ES_RETRY_JITTER %q: %w", v, err)
+		}
+		retry.Jitter, set = &f, true
+	}
+	for _, v := range splitList(os.Getenv("ES_RETRY_ON_STATUS")) {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("invalid ES_RETRY_ON_STATUS code %q: %w", v, err)
+		}
+		retry.RetryOnStatus, set = append(retry.RetryOnStatus, code), true
+	}
+	if set {
+		fc.Retry = retry
+	}
+
+	return fc.clientConfig()
+}
+
+func (fc fileConfig) clientConfig() (ClientConfig, error) {
+	cfg := ClientConfig{
+		Addresses:              fc.Addresses,
+		CloudID:                fc.CloudID,
+		Username:               fc.Username,
+		CertificateFingerprint: fc.CertificateFingerprint,
+	}
+
+	var err error
+	if cfg.Password, err = secret("password", fc.Password, fc.PasswordFile); err != nil {
+		return ClientConfig{}, err
+	}
+	if cfg.APIKey, err = secret("api key", fc.APIKey, fc.APIKeyFile); err != nil {
+		return ClientConfig{}, err
+	}
+	if cfg.ServiceToken, err = secret("service token", fc.ServiceToken, fc.ServiceTokenFile); err != nil {
+		return ClientConfig{}, err
+	}
+
+	if cfg.Timeout, err = parseDuration("timeout", fc.Timeout); err != nil {
+		return ClientConfig{}, err
+	}
+
+	if fc.Retry != nil {
+		retry := DefaultRetryConfig()
+		if fc.Retry.MaxRetries != nil {
+			retry.MaxRetries = *fc.Retry.MaxRetries
+		}
+		if fc.Retry.BackoffBase != "" {
+			if retry.BackoffBase, err = parseDuration("retry backoff base", fc.Retry.BackoffBase); err != nil {
+				return ClientConfig{}, err
+			}
+		}
+		if fc.Retry.BackoffCap != "" {
+			if retry.BackoffCap, err = parseDuration("retry backoff cap", fc.Retry.BackoffCap); err != nil {
+				return ClientConfig{}, err
+			}
+		}
+		if fc.Retry.Jitter != nil {
+			retry.Jitter = *fc.Retry.Jitter
+		}
+		if len(fc.Retry.RetryOnStatus) > 0 {
+			retry.RetryOnStatus = fc.Retry.RetryOnStatus
+		}
+		cfg.Retry = &retry
+	}
+
+	if fc.TLSReloadInterval != "" && fc.CACert == "" && fc.ClientCert == "" && fc.ClientKey == "" {
+		return ClientConfig{}, fmt.Errorf("TLS reload interval set without CA or client certificate files to reload")
+	}
+	if fc.CACert != "" || fc.ClientCert != "" || fc.ClientKey != "" || fc.TLSMinVersion != "" || fc.TLSServerName != "" {
+		opts := &TLSOptions{
+			CAFile:     fc.CACert,
+			CertFile:   fc.ClientCert,
+			KeyFile:    fc.ClientKey,
+			ServerName: fc.TLSServerName,
+		}
+		if opts.MinVersion, err = parseTLSVersion(fc.TLSMinVersion); err != nil {
+			return ClientConfig{}, err
+		}
+		if opts.ReloadInterval, err = parseDuration("TLS reload interval", fc.TLSReloadInterval); err != nil {
+			return ClientConfig{}, err
+		}
+		cfg.TLS = opts
+	}
+
+	return cfg, nil
+}
+
+// secret returns value, or the trimmed contents of file if it is set.
+func secret(name, value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("both %s and %s file are set", name, name)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s file: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, want 1.2 or 1.3", v)
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return d, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,201 @@
+package clients
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var envKeys = []string{
+	"ES_ADDRESSES", "ES_CLOUD_ID", "ES_USERNAME", "ES_PASSWORD", "ES_PASSWORD_FILE",
+	"ES_API_KEY", "ES_API_KEY_FILE", "ES_SERVICE_TOKEN", "ES_SERVICE_TOKEN_FILE",
+	"ES_CERT_FINGERPRINT", "ES_CA_CERT", "ES_CLIENT_CERT", "ES_CLIENT_KEY",
+	"ES_TLS_MIN_VERSION", "ES_TLS_SERVER_NAME", "ES_TLS_RELOAD_INTERVAL", "ES_TIMEOUT",
+	"ES_MAX_RETRIES", "ES_RETRY_BACKOFF_BASE", "ES_RETRY_BACKOFF_CAP", "ES_RETRY_JITTER", "ES_RETRY_ON_STATUS",
+}
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := writeFile(t, dir, "password", "s3cret\n")
+
+	retry := func(f func(r *RetryConfig)) *RetryConfig {
+		r := DefaultRetryConfig()
+		f(&r)
+		return &r
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ClientConfig
+		wantErr string
+	}{
+		{
+			name: "addresses and basic auth",
+			env:  map[string]string{"ES_ADDRESSES": "https://es1:9200, https://es2:9200,", "ES_USERNAME": "elastic", "ES_PASSWORD": "changeme"},
+			want: ClientConfig{Addresses: []string{"https://es1:9200", "https://es2:9200"}, Username: "elastic", Password: "changeme"},
+		},
+		{
+			name: "password file",
+			env:  map[string]string{"ES_CLOUD_ID": "deployment:abc", "ES_USERNAME": "elastic", "ES_PASSWORD_FILE": passwordFile},
+			want: ClientConfig{CloudID: "deployment:abc", Username: "elastic", Password: "s3cret"},
+		},
+		{
+			name:    "password and password file",
+			env:     map[string]string{"ES_PASSWORD": "changeme", "ES_PASSWORD_FILE": passwordFile},
+			wantErr: "both password and password file",
+		},
+		{
+			name:    "missing secret file",
+			env:     map[string]string{"ES_API_KEY_FILE": filepath.Join(dir, "missing")},
+			wantErr: "error reading api key file",
+		},
+		{
+			name: "timeout and retry",
+			env: map[string]string{
+				"ES_TIMEOUT": "10s", "ES_MAX_RETRIES": "5", "ES_RETRY_BACKOFF_BASE": "50ms",
+				"ES_RETRY_JITTER": "0.5", "ES_RETRY_ON_STATUS": "429, 503",
+			},
+			want: ClientConfig{Timeout: 10 * time.Second, Retry: retry(func(r *RetryConfig) {
+				r.MaxRetries, r.BackoffBase, r.Jitter, r.RetryOnStatus = 5, 50*time.Millisecond, 0.5, []int{429, 503}
+			})},
+		},
+		{
+			name: "backoff cap only",
+			env:  map[string]string{"ES_RETRY_BACKOFF_CAP": "1m"},
+			want: ClientConfig{Retry: retry(func(r *RetryConfig) { r.BackoffCap = time.Minute })},
+		},
+		{"invalid timeout", map[string]string{"ES_TIMEOUT": "10"}, ClientConfig{}, `invalid timeout "10"`},
+		{"invalid max retries", map[string]string{"ES_MAX_RETRIES": "many"}, ClientConfig{}, "invalid ES_MAX_RETRIES"},
+		{"invalid jitter", map[string]string{"ES_RETRY_JITTER": "half"}, ClientConfig{}, "invalid ES_RETRY_JITTER"},
+		{"invalid status", map[string]string{"ES_RETRY_ON_STATUS": "429,oops"}, ClientConfig{}, "invalid ES_RETRY_ON_STATUS"},
+		{"invalid backoff", map[string]string{"ES_RETRY_BACKOFF_BASE": "fast"}, ClientConfig{}, "invalid retry backoff base"},
+		{
+			name: "TLS options",
+			env: map[string]string{
+				"ES_CA_CERT": "/certs/ca.pem", "ES_CLIENT_CERT": "/certs/tls.crt", "ES_CLIENT_KEY": "/certs/tls.key",
+				"ES_TLS_MIN_VERSION": "1.3", "ES_TLS_SERVER_NAME": "es.local", "ES_TLS_RELOAD_INTERVAL": "1m",
+			},
+			want: ClientConfig{TLS: &TLSOptions{
+				CAFile: "/certs/ca.pem", CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key",
+				MinVersion: tls.VersionTLS13, ServerName: "es.local", ReloadInterval: time.Minute,
+			}},
+		},
+		{
+			name: "TLS server name only",
+			env:  map[string]string{"ES_TLS_SERVER_NAME": "es.local"},
+			want: ClientConfig{TLS: &TLSOptions{ServerName: "es.local"}},
+		},
+		{"reload interval only", map[string]string{"ES_TLS_RELOAD_INTERVAL": "1m"}, ClientConfig{}, "TLS reload interval set without"},
+		{"invalid reload interval", map[string]string{"ES_CA_CERT": "/certs/ca.pem", "ES_TLS_RELOAD_INTERVAL": "often"}, ClientConfig{}, "invalid TLS reload interval"},
+		{"invalid TLS version", map[string]string{"ES_TLS_MIN_VERSION": "1.1"}, ClientConfig{}, "unsupported TLS version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range envKeys {
+				t.Setenv(k, "")
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := ConfigFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConfigFromEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigFromFile(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := writeFile(t, dir, "token", "  AAEAAWVs\n")
+
+	want := ClientConfig{
+		Addresses:    []string{"https://es1:9200", "https://es2:9200"},
+		ServiceToken: "AAEAAWVs",
+		Timeout:      5 * time.Second,
+		Retry: func() *RetryConfig {
+			r := DefaultRetryConfig()
+			r.MaxRetries, r.BackoffCap = 0, 2*time.Second
+			return &r
+		}(),
+	}
+	yamlConfig := `
+addresses:
+  - https://es1:9200
+  - https://es2:9200
+service_token_file: ` + tokenFile + `
+timeout: 5s
+retry:
+  max_retries: 0
+  backoff_cap: 2s
+`
+	jsonConfig := `{
+	"addresses": ["https://es1:9200", "https://es2:9200"],
+	"service_token_file": "` + tokenFile + `",
+	"timeout": "5s",
+	"retry": {"max_retries": 0, "backoff_cap": "2s"}
+}`
+
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		want    ClientConfig
+		wantErr string
+	}{
+		{name: "yaml", file: "es.yaml", data: yamlConfig, want: want},
+		{name: "yml", file: "es.yml", data: yamlConfig, want: want},
+		{name: "json", file: "es.JSON", data: jsonConfig, want: want},
+		{name: "json in yaml file", file: "json.yaml", data: jsonConfig, want: want},
+		{name: "yaml in json file", file: "yaml.json", data: yamlConfig, wantErr: "error parsing client config"},
+		{name: "unsupported extension", file: "es.toml", data: `addresses = ["https://es1:9200"]`, wantErr: `unsupported client config format ".toml"`},
+		{name: "invalid duration", file: "timeout.yaml", data: "timeout: 5", wantErr: `invalid timeout "5"`},
+		{name: "reload interval only", file: "reload.yaml", data: "tls_reload_interval: 1m", wantErr: "TLS reload interval set without"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConfigFromFile(writeFile(t, dir, tt.file, tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConfigFromFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConfigFromFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ConfigFromFile(filepath.Join(dir, "missing.yaml")); err == nil || !strings.Contains(err.Error(), "error reading client config") {
+		t.Errorf("ConfigFromFile(missing) error = %v", err)
+	}
+}
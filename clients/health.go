@@ -0,0 +1,218 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+)
+
+// HealthStatus is the outcome of one health check.
+type HealthStatus struct {
+	Reachable      bool      `json:"reachable"`
+	Cluster        string    `json:"cluster,omitempty"` // "green", "yellow" or "red"
+	ClusterName    string    `json:"cluster_name,omitempty"`
+	NumberOfNodes  int       `json:"number_of_nodes"`
+	MissingIndices []string  `json:"missing_indices,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+
+	ready bool
+}
+
+// Ready reports whether the cluster was usable at the last check.
+func (s HealthStatus) Ready() bool {
+	return s.ready
+}
+
+// HealthMonitorConfig configures a HealthMonitor. Zero values use the defaults.
+type HealthMonitorConfig struct {
+	Interval time.Duration // defaults to 30s
+	Timeout  time.Duration // per check, defaults to 5s
+
+	// Indices must all exist for the cluster to be ready, e.g. the car,
+	// moto and truck indices or aliases.
+	Indices []string
+	// MinNodes is the minimum number of nodes for the cluster to be ready.
+	MinNodes int
+	// RequireGreen makes a yellow cluster not ready. A red cluster is never ready.
+	RequireGreen bool
+
+	// OnChange is called after a check whose readiness or cluster status
+	// differs from the previous one.
+	OnChange func(old, new HealthStatus)
+}
+
+// HealthMonitor periodically checks cluster health, node count and index
+// existence. It is also an http.Handler answering 200 when ready and 503
+// otherwise, for use as a readiness probe.
+type HealthMonitor struct {
+	client *es.Client
+	cfg    HealthMonitorConfig
+
+	mu      sync.RWMutex
+	status  HealthStatus
+	checked bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHealthMonitor returns a monitor for client. Call Start to begin checking.
+func NewHealthMonitor(client *es.Client, cfg HealthMonitorConfig) *HealthMonitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &HealthMonitor{client: client, cfg: cfg}
+}
+
+// Start runs a first check and then checks every Interval until Stop is
+// called or ctx is done.
+func (m *HealthMonitor) Start(ctx context.Context) {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	stop, done := m.stop, m.done
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			m.Check(ctx)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic checks and waits for a running check to finish.
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Status returns the result of the latest check.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Check runs a check now, records it as the latest status and returns it.
+func (m *HealthMonitor) Check(ctx context.Context) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	status := m.check(ctx)
+	status.CheckedAt = time.Now()
+
+	m.mu.Lock()
+	old, first := m.status, !m.checked
+	m.status, m.checked = status, true
+	m.mu.Unlock()
+
+	if m.cfg.OnChange != nil && (first || old.ready != status.ready || old.Cluster != status.Cluster) {
+		m.cfg.OnChange(old, status)
+	}
+	return status
+}
+
+func (m *HealthMonitor) check(ctx context.Context) HealthStatus {
+	var status HealthStatus
+
+	res, err := m.client.Cluster.Health(m.client.Cluster.Health.WithContext(ctx))
+	if err != nil {
+		status.Error = fmt.Sprintf("error checking cluster health: %v", err)
+		return status
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		status.Error = fmt.Sprintf("cluster health failed: %s", res.String())
+		return status
+	}
+
+	var health struct {
+		ClusterName   string `json:"cluster_name"`
+		Status        string `json:"status"`
+		NumberOfNodes int    `json:"number_of_nodes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		status.Error = fmt.Sprintf("error parsing cluster health: %v", err)
+		return status
+	}
+	status.Reachable = true
+	status.Cluster = health.Status
+	status.ClusterName = health.ClusterName
+	status.NumberOfNodes = health.NumberOfNodes
+
+	for _, index := range m.cfg.Indices {
+		res, err := m.client.Indices.Exists([]string{index}, m.client.Indices.Exists.WithContext(ctx))
+		if err != nil {
+			status.Error = fmt.Sprintf("error checking if index %s exists: %v", index, err)
+			return status
+		}
+		res.Body.Close()
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			status.MissingIndices = append(status.MissingIndices, index)
+		case res.IsError():
+			status.Error = fmt.Sprintf("failed to check if index %s exists: %s", index, res.Status())
+			return status
+		}
+	}
+
+	switch {
+	case status.Cluster == "red":
+	case status.Cluster == "yellow" && m.cfg.RequireGreen:
+	case status.NumberOfNodes < m.cfg.MinNodes:
+	case len(status.MissingIndices) > 0:
+	default:
+		status.ready = true
+	}
+	return status
+}
+
+// ServeHTTP writes the latest status as JSON, with status 200 when ready
+// and 503 otherwise, including before the first check completed.
+func (m *HealthMonitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	status := m.Status()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Ready() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Ready bool `json:"ready"`
+		HealthStatus
+	}{status.Ready(), status})
+}
@@ -0,0 +1,56 @@
+package clients
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// slogLogger logs the round trips of the client transport to a slog.Logger.
+type slogLogger struct {
+	logger *slog.Logger
+	bodies bool
+}
+
+func (l *slogLogger) LogRoundTrip(req *http.Request, res *http.Response, err error, _ time.Time, took time.Duration) error {
+	if req == nil {
+		return nil
+	}
+
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.Duration("took", took),
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+		if res.StatusCode >= 500 {
+			level = slog.LevelWarn
+		}
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	if l.bodies {
+		if req.Body != nil && req.Body != http.NoBody {
+			body, _ := io.ReadAll(req.Body)
+			req.Body.Close()
+			attrs = append(attrs, slog.String("request_body", string(body)))
+		}
+		if res != nil && res.Body != nil && res.Body != http.NoBody {
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			attrs = append(attrs, slog.String("response_body", string(body)))
+		}
+	}
+
+	l.logger.LogAttrs(req.Context(), level, "elasticsearch request", attrs...)
+	return nil
+}
+
+func (l *slogLogger) RequestBodyEnabled() bool  { return l.bodies }
+func (l *slogLogger) ResponseBodyEnabled() bool { return l.bodies }
@@ -0,0 +1,79 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryConfig controls how the client retries failed requests.
+// The zero value disables retries.
+type RetryConfig struct {
+	MaxRetries  int           // retries after the first attempt
+	BackoffBase time.Duration // delay before the first retry, doubled for each further one
+	BackoffCap  time.Duration // upper bound of the delay, if > 0
+	Jitter      float64       // fraction (0..1) of each delay that is randomised
+
+	// RetryOnStatus lists the response status codes that are retried.
+	RetryOnStatus []int
+	// RetryOnError decides whether a network error is retried. If nil, every
+	// error except a cancelled or expired request context is retried.
+	RetryOnError func(err error) bool
+}
+
+// DefaultRetryConfig is used when ClientConfig.Retry is nil: up to three
+// retries on 429, 502, 503 and 504 and on network errors, backing off from
+// 100ms up to 5s with full jitter.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:    3,
+		BackoffBase:   100 * time.Millisecond,
+		BackoffCap:    5 * time.Second,
+		Jitter:        1,
+		RetryOnStatus: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// Backoff returns the delay before retry number attempt, starting at 1.
+func (c RetryConfig) Backoff(attempt int) time.Duration {
+	if c.BackoffBase <= 0 || attempt < 1 {
+		return 0
+	}
+
+	d := c.BackoffBase
+	for i := 1; i < attempt && d < math.MaxInt64/2; i++ {
+		if c.BackoffCap > 0 && d >= c.BackoffCap {
+			break
+		}
+		d *= 2
+	}
+	if c.BackoffCap > 0 && d > c.BackoffCap {
+		d = c.BackoffCap
+	}
+
+	jitter := c.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if jitter > 0 {
+		spread := time.Duration(float64(d) * jitter)
+		if spread > 0 {
+			d = d - spread + rand.N(spread+1)
+		}
+	}
+	return d
+}
+
+// retryOnError adapts RetryOnError to the transport hook.
+func (c RetryConfig) retryOnError(_ *http.Request, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if c.RetryOnError != nil {
+		return c.RetryOnError(err)
+	}
+	return true
+}
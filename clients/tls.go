@@ -0,0 +1,246 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures TLS for the client without building a *tls.Config
+// by hand. Certificates can be given as PEM files or bytes; only files are
+// reloaded.
+type TLSOptions struct {
+	CAFile string // PEM bundle of the CAs trusted to sign the cluster certificates
+	CAPEM  []byte // same as CAFile, inline
+
+	// CertFile and KeyFile, or CertPEM and KeyPEM, are the client
+	// certificate and key presented for mutual TLS.
+	CertFile string
+	KeyFile  string
+	CertPEM  []byte
+	KeyPEM   []byte
+
+	MinVersion uint16 // defaults to tls.VersionTLS12
+	ServerName string // overrides the host name verified against the server certificate
+
+	// ReloadInterval is how often the certificate files are checked for
+	// changes. Changed files are picked up by new connections, so rotated
+	// certificates need no restart. Zero disables reloading.
+	ReloadInterval time.Duration
+}
+
+func (o *TLSOptions) validate() error {
+	if o.CAFile != "" && len(o.CAPEM) > 0 {
+		return errors.New("elasticsearch: both CA file and CA PEM provided")
+	}
+	files := o.CertFile != "" || o.KeyFile != ""
+	pems := len(o.CertPEM) > 0 || len(o.KeyPEM) > 0
+	switch {
+	case files && pems:
+		return errors.New("elasticsearch: client certificate given both as files and PEM")
+	case files && (o.CertFile == "" || o.KeyFile == ""):
+		return errors.New("elasticsearch: client certificate and key files must be set together")
+	case pems && (len(o.CertPEM) == 0 || len(o.KeyPEM) == 0):
+		return errors.New("elasticsearch: client certificate and key PEM must be set together")
+	}
+	return nil
+}
+
+// build loads the certificates and returns the resulting *tls.Config.
+func (o *TLSOptions) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: o.MinVersion,
+		ServerName: o.ServerName,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	ca := o.CAPEM
+	if o.CAFile != "" {
+		var err error
+		if ca, err = os.ReadFile(o.CAFile); err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %w", err)
+		}
+	}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates found in CA PEM")
+		}
+		cfg.RootCAs = pool
+	}
+
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	switch {
+	case o.CertFile != "":
+		cert, err = tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	case len(o.CertPEM) > 0:
+		cert, err = tls.X509KeyPair(o.CertPEM, o.KeyPEM)
+	default:
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+	return cfg, nil
+}
+
+// tlsReloader rebuilds the TLS config when one of the certificate files
+// changes. Checks happen lazily on dial, at most once per ReloadInterval.
+type tlsReloader struct {
+	opts TLSOptions
+
+	mu      sync.Mutex
+	cfg     *tls.Config
+	modTime map[string]time.Time
+	checked time.Time
+}
+
+func newTLSReloader(opts TLSOptions) (*tlsReloader, error) {
+	cfg, err := opts.build()
+	if err != nil {
+		return nil, err
+	}
+	r := &tlsReloader{opts: opts, cfg: cfg, checked: time.Now()}
+	r.modTime, _ = r.stat()
+	return r, nil
+}
+
+func (r *tlsReloader) stat() (map[string]time.Time, error) {
+	mod := map[string]time.Time{}
+	for _, path := range []string{r.opts.CAFile, r.opts.CertFile, r.opts.KeyFile} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		mod[path] = fi.ModTime()
+	}
+	return mod, nil
+}
+
+// config returns the current TLS config, reloading it if a file changed.
+// If reloading fails, e.g. while a certificate is half written, the
+// previous config stays in use and loading is retried on the next check.
+func (r *tlsReloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < r.opts.ReloadInterval {
+		return r.cfg
+	}
+	r.checked = time.Now()
+
+	mod, err := r.stat()
+	if err != nil {
+		return r.cfg
+	}
+	changed := false
+	for path, t := range mod {
+		if !t.Equal(r.modTime[path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return r.cfg
+	}
+
+	cfg, err := r.opts.build()
+	if err != nil {
+		return r.cfg
+	}
+	r.cfg, r.modTime = cfg, mod
+	return r.cfg
+}
+
+// dialTLSContext dials TLS connections with the current config.
+func (r *tlsReloader) dialTLSContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cfg := r.config()
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			cfg = cfg.Clone()
+			cfg.ServerName = host
+		}
+		d := &tls.Dialer{NetDialer: dialer, Config: cfg}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// fingerprintDialTLS dials TLS connections trusting a server whose leaf
+// certificate has the given hex SHA-256 fingerprint, or whose leaf is valid
+// for the dialed host and signed by the certificate with the fingerprint,
+// such as the cluster CA printed by Elasticsearch on first start.
+func fingerprintDialTLS(dialer *net.Dialer, fingerprint string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	want, _ := hex.DecodeString(fingerprint)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: host,
+			// The chain is checked against the fingerprint instead of a CA.
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				return verifyFingerprint(cs.PeerCertificates, want, host)
+			},
+		}
+		d := &tls.Dialer{NetDialer: dialer, Config: cfg}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// verifyFingerprint checks a server chain against a pinned certificate.
+// Matching any certificate of the chain is not enough: the CA certificate
+// is public, so it is only trusted as the root the leaf must verify against.
+func verifyFingerprint(certs []*x509.Certificate, want []byte, host string) error {
+	if len(certs) == 0 {
+		return errors.New("elasticsearch: server sent no certificate")
+	}
+	for i, cert := range certs {
+		sum := sha256.Sum256(cert.Raw)
+		if !bytes.Equal(sum[:], want) {
+			continue
+		}
+		if i == 0 {
+			return nil
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:i] {
+			intermediates.AddCert(c)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			DNSName:       host,
+		}); err != nil {
+			return fmt.Errorf("elasticsearch: server certificate not valid for the pinned certificate: %w", err)
+		}
+		return nil
+	}
+	return errors.New("elasticsearch: server certificate fingerprint mismatch")
+}
@@ -0,0 +1,82 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if !isCA {
+		tmpl.DNSNames = []string{cn}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func fingerprint(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.Raw)
+	return sum[:]
+}
+
+func TestVerifyFingerprint(t *testing.T) {
+	ca, caKey := newCert(t, "es-ca", true, nil, nil)
+	leaf, _ := newCert(t, "es.local", false, ca, caKey)
+	evil, _ := newCert(t, "es.local", false, nil, nil)
+	other, _ := newCert(t, "other-ca", true, nil, nil)
+
+	tests := []struct {
+		name    string
+		chain   []*x509.Certificate
+		pin     *x509.Certificate
+		host    string
+		wantErr bool
+	}{
+		{"leaf pinned", []*x509.Certificate{leaf, ca}, leaf, "es.local", false},
+		{"self-signed leaf pinned", []*x509.Certificate{evil}, evil, "es.local", false},
+		{"CA pinned", []*x509.Certificate{leaf, ca}, ca, "es.local", false},
+		{"CA pinned, foreign leaf with CA appended", []*x509.Certificate{evil, ca}, ca, "es.local", true},
+		{"CA pinned, wrong host", []*x509.Certificate{leaf, ca}, ca, "other.local", true},
+		{"no match", []*x509.Certificate{leaf, ca}, other, "es.local", true},
+		{"empty chain", nil, ca, "es.local", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyFingerprint(tt.chain, fingerprint(tt.pin), tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyFingerprint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"crypto/tls"
 	"fmt"
 	"log"
 	"time"
@@ -19,13 +18,14 @@ func ptrString(s string) *string { return &s }
 func ptrInt64(i int64) *int64    { return &i }
 
 func main() {
-	es, err := esadapter.NewClient(esadapter.ClientConfig{
-		Addresses: []string{"http://10.192.1.127:9200"},
-		Username:  "elastic",
-		Password:  "1Z*Ywl6qbw0PXqoeKzJ3",
-		Timeout:   10 * time.Second,
-		TLSConfig: &tls.Config{InsecureSkipVerify: true},
-	})
+	// Connection settings come from ES_ADDRESSES, ES_USERNAME,
+	// ES_PASSWORD_FILE etc., see clients.ConfigFromEnv.
+	cfg, err := esadapter.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("client config: %v", err)
+	}
+
+	es, err := esadapter.NewClient(cfg)
 	if err != nil {
 		log.Fatalf("new client: %v", err)
 	}
@@ -0,0 +1,168 @@
+// Package eserrors turns Elasticsearch error responses into typed errors.
+//
+// Every failed response is returned as an *Error carrying the status, error
+// type, reason and root cause. Callers match categories with errors.Is:
+//
+//	if errors.Is(err, eserrors.ErrNotFound) { ... }
+//
+// and read the details with errors.As:
+//
+//	var esErr *eserrors.Error
+//	if errors.As(err, &esErr) { log.Println(esErr.Type, esErr.Reason) }
+//
+// Requests that got no response fail with the error of the transport
+// instead, such as a net.Error or a context error, which is not an *Error.
+// A request rejected by the client's circuit breaker fails with
+// clients.ErrCircuitOpen, which matches ErrUnavailable.
+package eserrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Error categories, matched by errors.Is on an *Error.
+var (
+	ErrNotFound        = errors.New("elasticsearch: document not found")
+	ErrIndexNotFound   = errors.New("elasticsearch: index not found")
+	ErrConflict        = errors.New("elasticsearch: version conflict")
+	ErrMappingConflict = errors.New("elasticsearch: mapping conflict")
+	ErrRejected        = errors.New("elasticsearch: request rejected")
+	ErrUnavailable     = errors.New("elasticsearch: cluster unavailable")
+)
+
+// Cause is one entry of the root cause of an Elasticsearch error.
+type Cause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index,omitempty"`
+}
+
+// Error is an Elasticsearch error response.
+type Error struct {
+	Status    int
+	Type      string // e.g. version_conflict_engine_exception; empty if the body had no error object
+	Reason    string
+	Index     string
+	RootCause []Cause
+}
+
+func (e *Error) Error() string {
+	var msg string
+	if e.Status != 0 {
+		msg = fmt.Sprintf("[%d %s] ", e.Status, http.StatusText(e.Status))
+	}
+	msg += e.Type
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	for _, c := range e.RootCause {
+		if c.Type != e.Type || c.Reason != e.Reason {
+			msg += fmt.Sprintf(" (root cause %s: %s)", c.Type, c.Reason)
+			break
+		}
+	}
+	return msg
+}
+
+// Is reports whether the error belongs to the category target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrIndexNotFound:
+		return e.hasType("index_not_found_exception")
+	case ErrNotFound:
+		return e.hasType("document_missing_exception") ||
+			(e.Status == http.StatusNotFound && e.Type == "")
+	case ErrConflict:
+		return e.hasType("version_conflict_engine_exception") ||
+			(e.Status == http.StatusConflict && !e.hasType("resource_already_exists_exception"))
+	case ErrMappingConflict:
+		return e.hasType("mapper_parsing_exception", "document_parsing_exception",
+			"strict_dynamic_mapping_exception", "mapper_exception") ||
+			(e.hasType("illegal_argument_exception") && strings.Contains(e.Reason, "mapper"))
+	case ErrRejected:
+		return e.Status == http.StatusTooManyRequests ||
+			e.hasType("es_rejected_execution_exception", "circuit_breaking_exception")
+	case ErrUnavailable:
+		return e.Status == http.StatusBadGateway ||
+			e.Status == http.StatusServiceUnavailable ||
+			e.Status == http.StatusGatewayTimeout ||
+			e.hasType("no_shard_available_action_exception", "master_not_discovered_exception",
+				"unavailable_shards_exception", "node_not_connected_exception",
+				"node_disconnected_exception", "connect_transport_exception")
+	}
+	return false
+}
+
+// hasType reports whether the error or one of its root causes has one of
+// the given types.
+func (e *Error) hasType(types ...string) bool {
+	for _, t := range types {
+		if e.Type == t {
+			return true
+		}
+		for _, c := range e.RootCause {
+			if c.Type == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// New returns an *Error without root cause, e.g. for a failed bulk item.
+// A zero status means none was reported.
+func New(status int, typ, reason string) *Error {
+	return &Error{Status: status, Type: typ, Reason: reason}
+}
+
+// FromResponse returns the *Error described by a failed response, or nil if
+// res is not an error. It reads the response body.
+func FromResponse(res *esapi.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+	if res.Body == nil {
+		return &Error{Status: res.StatusCode}
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return &Error{Status: res.StatusCode}
+	}
+	return Parse(res.StatusCode, data)
+}
+
+// Parse returns the *Error described by an error response body.
+// Elasticsearch sends an error object, a plain error string, or for missing
+// documents no error at all.
+func Parse(status int, data []byte) *Error {
+	e := &Error{Status: status}
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Error) == 0 {
+		return e
+	}
+
+	var obj struct {
+		Type      string  `json:"type"`
+		Reason    string  `json:"reason"`
+		Index     string  `json:"index"`
+		RootCause []Cause `json:"root_cause"`
+	}
+	if err := json.Unmarshal(body.Error, &obj); err == nil {
+		e.Type, e.Reason, e.Index, e.RootCause = obj.Type, obj.Reason, obj.Index, obj.RootCause
+		return e
+	}
+	var reason string
+	if err := json.Unmarshal(body.Error, &reason); err == nil {
+		e.Reason = reason
+	}
+	return e
+}
@@ -0,0 +1,101 @@
+package eserrors
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *Error
+	}{
+		{
+			name:   "error object",
+			status: 404,
+			body: `{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index [cars]","index":"cars"}],
+				"type":"index_not_found_exception","reason":"no such index [cars]","index":"cars"},"status":404}`,
+			want: &Error{
+				Status:    404,
+				Type:      "index_not_found_exception",
+				Reason:    "no such index [cars]",
+				Index:     "cars",
+				RootCause: []Cause{{Type: "index_not_found_exception", Reason: "no such index [cars]", Index: "cars"}},
+			},
+		},
+		{
+			name:   "error string",
+			status: 400,
+			body:   `{"error":"Incorrect HTTP method for uri [/cars/_doc]","status":400}`,
+			want:   &Error{Status: 400, Reason: "Incorrect HTTP method for uri [/cars/_doc]"},
+		},
+		{
+			name:   "missing document",
+			status: 404,
+			body:   `{"_index":"cars","_id":"1","found":false}`,
+			want:   &Error{Status: 404},
+		},
+		{
+			name:   "not JSON",
+			status: 502,
+			body:   `<html>Bad Gateway</html>`,
+			want:   &Error{Status: 502},
+		},
+		{
+			name:   "empty body",
+			status: 503,
+			want:   &Error{Status: 503},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.status, []byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	all := []error{ErrNotFound, ErrIndexNotFound, ErrConflict, ErrMappingConflict, ErrRejected, ErrUnavailable}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   []error
+	}{
+		{"missing document", 404, `{"_index":"cars","_id":"1","found":false}`, []error{ErrNotFound}},
+		{"missing index", 404, `{"error":{"type":"index_not_found_exception","reason":"no such index [cars]"},"status":404}`, []error{ErrIndexNotFound}},
+		{"missing document on update", 404, `{"error":{"type":"document_missing_exception","reason":"[1]: document missing"},"status":404}`, []error{ErrNotFound}},
+		{"version conflict", 409, `{"error":{"type":"version_conflict_engine_exception","reason":"[1]: version conflict"},"status":409}`, []error{ErrConflict}},
+		{"index exists", 400, `{"error":{"type":"resource_already_exists_exception","reason":"index [cars] already exists"},"status":400}`, nil},
+		{"mapper parsing", 400, `{"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [price]"},"status":400}`, []error{ErrMappingConflict}},
+		{"mapper illegal argument", 400, `{"error":{"type":"illegal_argument_exception","reason":"mapper [price] cannot be changed from type [long] to [text]"},"status":400}`, []error{ErrMappingConflict}},
+		{"unrelated illegal argument", 400, `{"error":{"type":"illegal_argument_exception","reason":"unknown setting"},"status":400}`, nil},
+		{"too many requests", 429, `{"error":{"type":"es_rejected_execution_exception","reason":"rejected execution"},"status":429}`, []error{ErrRejected}},
+		{"circuit breaking", 500, `{"error":{"type":"circuit_breaking_exception","reason":"[parent] Data too large"},"status":500}`, []error{ErrRejected}},
+		{"no shard available", 500, `{"error":{"root_cause":[{"type":"no_shard_available_action_exception","reason":"No shard available"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":500}`, []error{ErrUnavailable}},
+		{"master not discovered", 503, `{"error":{"type":"master_not_discovered_exception","reason":null},"status":503}`, []error{ErrUnavailable}},
+		{"bad gateway", 502, `<html>Bad Gateway</html>`, []error{ErrUnavailable}},
+		{"gateway timeout", 504, ``, []error{ErrUnavailable}},
+		{"string body", 400, `{"error":"Incorrect HTTP method","status":400}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(Parse(tt.status, []byte(tt.body)))
+			for _, target := range all {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == target
+				}
+				if got := errors.Is(err, target); got != want {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", err, target, got, want)
+				}
+			}
+		})
+	}
+}
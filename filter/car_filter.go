@@ -1,10 +1,7 @@
 package filter
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
@@ -12,6 +9,7 @@ import (
 )
 
 type CarFilter struct {
+	Query             string // free-text search over descriptions and names
 	BrandID           []int64
 	ModelID           []int64
 	StockID           []int64
@@ -40,53 +38,72 @@ type CarFilter struct {
 	CreatedAtMax      time.Time
 	IsCompany         *bool
 	IsPrivate         *bool
+	Facets            bool // return facet counts with the results
 }
 
-func SearchCars(client *elasticsearch.Client, index string, filter *CarFilter) ([]models.Car, error) {
-	query := buildESQuery(filter)
+func SearchCars(client *elasticsearch.Client, index string, filter *CarFilter) (*SearchResult[models.Car], error) {
+	return SearchCarsContext(context.Background(), client, index, filter)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, fmt.Errorf("error encoding query: %s", err)
-	}
+// SearchCarsContext returns the cars in index matching filter.
+// If filter.Facets is set the result also carries the sidebar facet counts.
+func SearchCarsContext(ctx context.Context, client *elasticsearch.Client, index string, filter *CarFilter) (*SearchResult[models.Car], error) {
+	ctx, span := startSearchSpan(ctx, "SearchCars", "car", index, filter)
+	defer span.End()
 
-	res, err := client.Search(
-		client.Search.WithContext(context.Background()),
-		client.Search.WithIndex(index),
-		client.Search.WithBody(&buf),
-		client.Search.WithTrackTotalHits(true),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error getting response: %s", err)
+	var facets []facet
+	if filter.Facets {
+		facets = carFacets
 	}
-	defer res.Body.Close()
+	return search[models.Car](ctx, client, index, buildESQuery(filter), facets)
+}
 
-	if res.IsError() {
-		return nil, fmt.Errorf("error response: %s", res.String())
-	}
+// SearchCarsAfter returns the page of cars following cursor, using a
+// point-in-time so pages stay consistent while listings are indexed.
+// Pass an empty cursor for the first page and the returned Cursor for the
+// next one; filter.Page is ignored.
+func SearchCarsAfter(ctx context.Context, client *elasticsearch.Client, index string, filter *CarFilter, cursor string) (*SearchResult[models.Car], error) {
+	ctx, span := startSearchSpan(ctx, "SearchCarsAfter", "car", index, filter)
+	defer span.End()
 
-	var r struct {
-		Hits struct {
-			Hits []struct {
-				Source models.Car `json:"_source"`
-			} `json:"hits"`
-		} `json:"hits"`
+	var facets []facet
+	if filter.Facets {
+		facets = carFacets
 	}
+	return searchAfter[models.Car](ctx, client, index, buildESQuery(filter), facets, cursor)
+}
 
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, fmt.Errorf("error parsing response body: %s", err)
-	}
+// CountCars returns the number of cars in index matching filter, e.g.
+// for a "show N results" button. Paging, sorting and facets are ignored.
+func CountCars(ctx context.Context, client *elasticsearch.Client, index string, filter *CarFilter) (int64, error) {
+	ctx, span := startSearchSpan(ctx, "CountCars", "car", index, filter)
+	defer span.End()
 
-	carsList := make([]models.Car, len(r.Hits.Hits))
-	for i, hit := range r.Hits.Hits {
-		carsList[i] = hit.Source
+	return count(ctx, client, index, buildESQuery(filter))
+}
+
+// MultiCountCars returns the number of cars matching each of filters,
+// in order, using a single multi search request.
+func MultiCountCars(ctx context.Context, client *elasticsearch.Client, index string, filters []*CarFilter) ([]int64, error) {
+	ctx, span := startSearchSpan(ctx, "MultiCountCars", "car", index, nil)
+	defer span.End()
+
+	queries := make([]map[string]interface{}, len(filters))
+	for i, filter := range filters {
+		queries[i] = buildESQuery(filter)
 	}
-	return carsList, nil
+	return countMany(ctx, client, index, queries)
 }
 
+var carFacets = []facet{brandFacet, modelFacet, cityFacet, colorFacet, engineTypeFacet, transmissionFacet, bodyFacet, priceFacet, yearFacet, mileageFacet}
+
 func buildESQuery(filter *CarFilter) map[string]interface{} {
 	must := []map[string]interface{}{}
 
+	if filter.Query != "" {
+		must = append(must, fullTextClause(filter.Query))
+	}
+
 	if len(filter.BrandID) > 0 {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"brand_id": filter.BrandID}})
 	}
@@ -120,13 +137,13 @@ func buildESQuery(filter *CarFilter) map[string]interface{} {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"city_id": filter.CityID}})
 	}
 	if len(filter.EngineType) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"engine_type.keyword": filter.EngineType}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"engine_type": filter.EngineType}})
 	}
 	if len(filter.Transmission) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"transmission.keyword": filter.Transmission}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"transmission": filter.Transmission}})
 	}
 	if len(filter.DriveType) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"drive_type.keyword": filter.DriveType}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"drive_type": filter.DriveType}})
 	}
 	if len(filter.BodyID) > 0 {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"body_id": filter.BodyID}})
@@ -152,7 +169,7 @@ func buildESQuery(filter *CarFilter) map[string]interface{} {
 		must = append(must, map[string]interface{}{"range": map[string]interface{}{"engine_capacity": r}})
 	}
 	if len(filter.Color) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"color.keyword": filter.Color}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"color": filter.Color}})
 	}
 	if filter.IsExchange != nil {
 		must = append(must, map[string]interface{}{"term": map[string]interface{}{"is_exchange": *filter.IsExchange}})
@@ -161,7 +178,7 @@ func buildESQuery(filter *CarFilter) map[string]interface{} {
 		must = append(must, map[string]interface{}{"term": map[string]interface{}{"is_credit": *filter.IsCredit}})
 	}
 	if len(filter.Status) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"status.keyword": filter.Status}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"status": filter.Status}})
 	}
 	if !filter.CreatedAtMin.IsZero() || !filter.CreatedAtMax.IsZero() {
 		r := map[string]interface{}{}
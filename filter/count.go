@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/go-elasticsearch/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// countBody keeps only the query of a search body built by the filter
+// builders, dropping size, from and sort, which the _count API rejects.
+func countBody(query map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"query": query["query"]}
+}
+
+// count returns the number of documents in index matching query.
+func count(ctx context.Context, client *elasticsearch.Client, index string, query map[string]interface{}) (_ int64, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.Get().ObserveOperation("count", index, time.Since(start), err)
+		if err != nil {
+			spanError(ctx, err)
+		}
+	}()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(countBody(query)); err != nil {
+		return 0, fmt.Errorf("error encoding query: %w", err)
+	}
+
+	res, err := client.Count(
+		client.Count.WithContext(ctx),
+		client.Count.WithIndex(index),
+		client.Count.WithBody(&buf),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error getting response: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("error response: %w", eserrors.FromResponse(res))
+	}
+
+	var r struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return 0, fmt.Errorf("error parsing response body: %w", err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("search.total", r.Count))
+	return r.Count, nil
+}
+
+// countMany returns the number of documents in index matching each of
+// queries, using a single multi search request.
+func countMany(ctx context.Context, client *elasticsearch.Client, index string, queries []map[string]interface{}) (_ []int64, err error) {
+	if len(queries) == 0 {
+		return []int64{}, nil
+	}
+	start := time.Now()
+	defer func() {
+		metrics.Get().ObserveOperation("multi_count", index, time.Since(start), err)
+		if err != nil {
+			spanError(ctx, err)
+		}
+	}()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("search.batch_size", len(queries)))
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, query := range queries {
+		body := countBody(query)
+		body["size"] = 0
+		body["track_total_hits"] = true
+		if err := enc.Encode(map[string]interface{}{"index": index}); err != nil {
+			return nil, fmt.Errorf("error encoding query: %w", err)
+		}
+		if err := enc.Encode(body); err != nil {
+			return nil, fmt.Errorf("error encoding query: %w", err)
+		}
+	}
+
+	res, err := client.Msearch(
+		&buf,
+		client.Msearch.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting response: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error response: %w", eserrors.FromResponse(res))
+	}
+
+	var r struct {
+		Responses []json.RawMessage `json:"responses"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing response body: %w", err)
+	}
+	if len(r.Responses) != len(queries) {
+		return nil, fmt.Errorf("multi search response has %d responses, expected %d", len(r.Responses), len(queries))
+	}
+
+	counts := make([]int64, len(queries))
+	for i, raw := range r.Responses {
+		var item struct {
+			Status int `json:"status"`
+			Hits   struct {
+				Total struct {
+					Value int64 `json:"value"`
+				} `json:"total"`
+			} `json:"hits"`
+			Error json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, fmt.Errorf("error parsing response body: %w", err)
+		}
+		if len(item.Error) > 0 {
+			return nil, fmt.Errorf("error response for query %d: %w", i, eserrors.Parse(item.Status, raw))
+		}
+		counts[i] = item.Hits.Total.Value
+	}
+	return counts, nil
+}
@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// CursorKeepAlive is how long a point-in-time opened for cursor pagination
+// stays alive between two pages.
+var CursorKeepAlive = 5 * time.Minute
+
+// ErrInvalidCursor is returned when a cursor token cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque token handed to clients.
+type cursor struct {
+	PitID       string        `json:"p"`
+	SearchAfter []interface{} `json:"a"`
+	Page        int           `json:"n"`
+}
+
+func (c cursor) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("error encoding cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.PitID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// searchAfter runs query as one page of a point-in-time search. An empty
+// token opens a new point-in-time on index. The returned result carries the
+// token for the next page, or an empty Cursor once the last page is reached,
+// at which point the point-in-time is closed.
+func searchAfter[T any](ctx context.Context, client *elasticsearch.Client, index string, query map[string]interface{}, facets []facet, token string) (_ *SearchResult[T], err error) {
+	searchFailed := false // search marks its own failures on the span
+	defer func() {
+		if err != nil && !searchFailed {
+			spanError(ctx, err)
+		}
+	}()
+
+	c := cursor{Page: 1}
+	if token != "" {
+		var err error
+		if c, err = decodeCursor(token); err != nil {
+			return nil, err
+		}
+	} else {
+		pitID, err := openPointInTime(ctx, client, index)
+		if err != nil {
+			return nil, err
+		}
+		c.PitID = pitID
+	}
+
+	keepAlive := fmt.Sprintf("%ds", int(CursorKeepAlive.Seconds()))
+
+	delete(query, "from")
+	query["pit"] = map[string]interface{}{"id": c.PitID, "keep_alive": keepAlive}
+
+	sort, _ := query["sort"].([]map[string]interface{})
+	if len(sort) == 0 {
+		sort = []map[string]interface{}{{"_score": map[string]interface{}{"order": "desc"}}}
+	}
+	query["sort"] = append(sort, map[string]interface{}{"_shard_doc": map[string]interface{}{"order": "asc"}})
+
+	if len(c.SearchAfter) > 0 {
+		query["search_after"] = c.SearchAfter
+	}
+
+	result, err := search[T](ctx, client, index, query, facets)
+	if err != nil {
+		searchFailed = true
+		// No token reaches the caller, so a point-in-time opened here
+		// would stay alive until it expires.
+		if token == "" {
+			if cerr := closePointInTime(context.WithoutCancel(ctx), client, c.PitID); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+		}
+		return nil, err
+	}
+	result.Page = c.Page
+
+	if len(result.Hits) == 0 || len(result.Hits) < result.PageSize {
+		if err := closePointInTime(ctx, client, result.pitID); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	next := cursor{
+		PitID:       result.pitID,
+		SearchAfter: result.Hits[len(result.Hits)-1].Sort,
+		Page:        c.Page + 1,
+	}
+	if next.PitID == "" {
+		next.PitID = c.PitID
+	}
+	if result.Cursor, err = next.encode(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ClosePointInTime releases the point-in-time behind a cursor that will not
+// be followed to the last page. Closing an already closed cursor is a no-op.
+func ClosePointInTime(ctx context.Context, client *elasticsearch.Client, token string) error {
+	c, err := decodeCursor(token)
+	if err != nil {
+		return err
+	}
+	return closePointInTime(ctx, client, c.PitID)
+}
+
+func openPointInTime(ctx context.Context, client *elasticsearch.Client, index string) (string, error) {
+	res, err := client.OpenPointInTime(
+		[]string{index},
+		fmt.Sprintf("%ds", int(CursorKeepAlive.Seconds())),
+		client.OpenPointInTime.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error opening point in time: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", fmt.Errorf("error opening point in time: %w", eserrors.FromResponse(res))
+	}
+
+	var r struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("error parsing point in time response: %w", err)
+	}
+	return r.ID, nil
+}
+
+func closePointInTime(ctx context.Context, client *elasticsearch.Client, pitID string) error {
+	if pitID == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]string{"id": pitID})
+	if err != nil {
+		return fmt.Errorf("error encoding point in time: %w", err)
+	}
+
+	res, err := client.ClosePointInTime(
+		client.ClosePointInTime.WithContext(ctx),
+		client.ClosePointInTime.WithBody(bytes.NewReader(data)),
+	)
+	if err != nil {
+		return fmt.Errorf("error closing point in time: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("error closing point in time: %w", eserrors.FromResponse(res))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   cursor
+		want cursor
+	}{
+		{
+			name: "first page",
+			in:   cursor{PitID: "pit-1", Page: 1},
+			want: cursor{PitID: "pit-1", Page: 1},
+		},
+		{
+			name: "sort values keep their precision",
+			in:   cursor{PitID: "pit-1", SearchAfter: []interface{}{1.5, int64(1712345678901234567), "bmw", nil}, Page: 3},
+			want: cursor{PitID: "pit-1", SearchAfter: []interface{}{json.Number("1.5"), json.Number("1712345678901234567"), "bmw", nil}, Page: 3},
+		},
+		{
+			name: "json.Number sort values",
+			in:   cursor{PitID: "pit-2", SearchAfter: []interface{}{json.Number("9007199254740993"), json.Number("42")}, Page: 2},
+			want: cursor{PitID: "pit-2", SearchAfter: []interface{}{json.Number("9007199254740993"), json.Number("42")}, Page: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.in.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeCursor(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor(encode()) = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "not a token!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"p":"pit-1","n":1}`))},
+		{"not JSON", encode("pit-1")},
+		{"wrong types", encode(`{"p":1,"n":"1"}`)},
+		{"no point in time", encode(`{"a":[1],"n":2}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestSearchAfterClosesPointInTimeOnError(t *testing.T) {
+	var closed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/cars/_pit":
+			w.Write([]byte(`{"id":"pit-1"}`))
+		case r.URL.Path == "/_search":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"search_phase_execution_exception","reason":"all shards failed"},"status":400}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			var body struct {
+				ID string `json:"id"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			closed = append(closed, body.ID)
+			w.Write([]byte(`{"succeeded":true,"num_freed":1}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}
+	if _, err := searchAfter[struct{}](context.Background(), client, "cars", query, nil, ""); err == nil {
+		t.Fatal("searchAfter() succeeded, want error")
+	}
+	if !reflect.DeepEqual(closed, []string{"pit-1"}) {
+		t.Errorf("closed point-in-times = %v, want [pit-1]", closed)
+	}
+
+	closed = nil
+	token, err := cursor{PitID: "pit-2", Page: 2}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := searchAfter[struct{}](context.Background(), client, "cars", query, nil, token); err == nil {
+		t.Fatal("searchAfter() succeeded, want error")
+	}
+	if len(closed) != 0 {
+		t.Errorf("closed point-in-times %v of a caller's cursor", closed)
+	}
+}
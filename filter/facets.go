@@ -0,0 +1,182 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FacetRange is a bucket boundary for a numeric facet. Nil bounds are open.
+type FacetRange struct {
+	From *float64
+	To   *float64
+}
+
+// FacetBucket is a single value of a facet and the number of matching documents.
+type FacetBucket struct {
+	Key   string
+	From  *float64
+	To    *float64
+	Count int64
+}
+
+// Default range buckets for the numeric facets. Callers may replace them.
+var (
+	PriceFacetRanges = []FacetRange{
+		{To: f64(5000)}, {From: f64(5000), To: f64(10000)}, {From: f64(10000), To: f64(20000)},
+		{From: f64(20000), To: f64(50000)}, {From: f64(50000), To: f64(100000)}, {From: f64(100000)},
+	}
+	YearFacetRanges = []FacetRange{
+		{To: f64(2000)}, {From: f64(2000), To: f64(2010)}, {From: f64(2010), To: f64(2015)},
+		{From: f64(2015), To: f64(2020)}, {From: f64(2020)},
+	}
+	MileageFacetRanges = []FacetRange{
+		{To: f64(10000)}, {From: f64(10000), To: f64(50000)}, {From: f64(50000), To: f64(100000)},
+		{From: f64(100000), To: f64(200000)}, {From: f64(200000)},
+	}
+)
+
+// facetTermsSize is the maximum number of buckets returned for a terms facet.
+const facetTermsSize = 200
+
+// facet describes one sidebar facet: a terms facet when ranges is nil,
+// otherwise a range facet.
+type facet struct {
+	name   string
+	field  string
+	ranges *[]FacetRange
+}
+
+func (f facet) agg() map[string]interface{} {
+	if f.ranges == nil {
+		return map[string]interface{}{"terms": map[string]interface{}{"field": f.field, "size": facetTermsSize}}
+	}
+
+	ranges := make([]map[string]interface{}, 0, len(*f.ranges))
+	for _, r := range *f.ranges {
+		b := map[string]interface{}{}
+		if r.From != nil {
+			b["from"] = *r.From
+		}
+		if r.To != nil {
+			b["to"] = *r.To
+		}
+		ranges = append(ranges, b)
+	}
+	return map[string]interface{}{"range": map[string]interface{}{"field": f.field, "ranges": ranges}}
+}
+
+var (
+	brandFacet        = facet{name: "brand", field: "brand_id"}
+	modelFacet        = facet{name: "model", field: "model_id"}
+	cityFacet         = facet{name: "city", field: "city_id"}
+	colorFacet        = facet{name: "color", field: "color"}
+	engineTypeFacet   = facet{name: "engine_type", field: "engine_type"}
+	transmissionFacet = facet{name: "transmission", field: "transmission"}
+	bodyFacet         = facet{name: "body", field: "body_id"}
+	priceFacet        = facet{name: "price", field: "price", ranges: &PriceFacetRanges}
+	yearFacet         = facet{name: "year", field: "year", ranges: &YearFacetRanges}
+	mileageFacet      = facet{name: "mileage", field: "mileage", ranges: &MileageFacetRanges}
+)
+
+// applyFacets moves the clauses on faceted fields from the query into a
+// post_filter and adds one aggregation per facet. Each aggregation is
+// filtered by every selected facet except its own, so selecting a value
+// does not collapse the counts of its siblings.
+func applyFacets(query map[string]interface{}, facets []facet) {
+	boolQuery := query["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	must, _ := boolQuery["must"].([]map[string]interface{})
+
+	byField := make(map[string]string, len(facets))
+	for _, f := range facets {
+		byField[f.field] = f.name
+	}
+
+	base := []map[string]interface{}{}
+	selected := map[string][]map[string]interface{}{}
+	for _, clause := range must {
+		if name, ok := byField[clauseField(clause)]; ok {
+			selected[name] = append(selected[name], clause)
+		} else {
+			base = append(base, clause)
+		}
+	}
+	boolQuery["must"] = base
+
+	post := []map[string]interface{}{}
+	for _, f := range facets {
+		post = append(post, selected[f.name]...)
+	}
+	if len(post) > 0 {
+		query["post_filter"] = map[string]interface{}{"bool": map[string]interface{}{"must": post}}
+	}
+
+	aggs := map[string]interface{}{}
+	for _, f := range facets {
+		others := []map[string]interface{}{}
+		for _, g := range facets {
+			if g.name != f.name {
+				others = append(others, selected[g.name]...)
+			}
+		}
+		aggs[f.name] = map[string]interface{}{
+			"filter": map[string]interface{}{"bool": map[string]interface{}{"must": others}},
+			"aggs":   map[string]interface{}{f.name: f.agg()},
+		}
+	}
+	query["aggs"] = aggs
+}
+
+// parseFacets extracts the facet buckets added by applyFacets.
+func parseFacets(aggs map[string]json.RawMessage, facets []facet) (map[string][]FacetBucket, error) {
+	result := make(map[string][]FacetBucket, len(facets))
+	for _, f := range facets {
+		raw, ok := aggs[f.name]
+		if !ok {
+			continue
+		}
+
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, fmt.Errorf("error parsing facet %s: %w", f.name, err)
+		}
+
+		var inner struct {
+			Buckets []struct {
+				Key      json.RawMessage `json:"key"`
+				From     *float64        `json:"from"`
+				To       *float64        `json:"to"`
+				DocCount int64           `json:"doc_count"`
+			} `json:"buckets"`
+		}
+		if err := json.Unmarshal(wrapper[f.name], &inner); err != nil {
+			return nil, fmt.Errorf("error parsing facet %s: %w", f.name, err)
+		}
+
+		buckets := make([]FacetBucket, len(inner.Buckets))
+		for i, b := range inner.Buckets {
+			buckets[i] = FacetBucket{
+				Key:   strings.Trim(string(b.Key), `"`),
+				From:  b.From,
+				To:    b.To,
+				Count: b.DocCount,
+			}
+		}
+		result[f.name] = buckets
+	}
+	return result, nil
+}
+
+// clauseField returns the field targeted by a term, terms or range clause.
+func clauseField(clause map[string]interface{}) string {
+	for _, kind := range []string{"term", "terms", "range"} {
+		if inner, ok := clause[kind].(map[string]interface{}); ok {
+			for field := range inner {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+func f64(v float64) *float64 { return &v }
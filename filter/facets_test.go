@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyFacets(t *testing.T) {
+	priceMin := int64(5000)
+	company := true
+
+	brand := map[string]interface{}{"terms": map[string]interface{}{"brand_id": []int64{1, 2}}}
+	price := map[string]interface{}{"range": map[string]interface{}{"price": map[string]interface{}{"gte": priceMin}}}
+
+	// base is the part of every filter that is not faceted and stays in query.
+	base := func() *CarFilter {
+		return &CarFilter{Query: "camry", Status: []string{"active"}, IsCompany: &company}
+	}
+
+	tests := []struct {
+		name   string
+		filter func(f *CarFilter)
+		post   []map[string]interface{}            // nil means no post_filter
+		aggs   map[string][]map[string]interface{} // filter of each aggregation, empty if absent
+	}{
+		{
+			name: "brand and price selected",
+			filter: func(f *CarFilter) {
+				f.BrandID = []int64{1, 2}
+				f.PriceMin = &priceMin
+			},
+			post: []map[string]interface{}{brand, price},
+			aggs: map[string][]map[string]interface{}{
+				"brand": {price},
+				"price": {brand},
+				"model": {brand, price},
+				"year":  {brand, price},
+			},
+		},
+		{
+			name:   "brand selected",
+			filter: func(f *CarFilter) { f.BrandID = []int64{1, 2} },
+			post:   []map[string]interface{}{brand},
+			aggs: map[string][]map[string]interface{}{
+				"brand": {},
+				"price": {brand},
+			},
+		},
+		{
+			name:   "no facet selected",
+			filter: func(f *CarFilter) {},
+			aggs: map[string][]map[string]interface{}{
+				"brand": {},
+				"price": {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := base()
+			tt.filter(f)
+			query := buildESQuery(f)
+			applyFacets(query, carFacets)
+
+			wantMust := buildESQuery(base())["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"]
+			if n := len(wantMust.([]map[string]interface{})); n != 3 {
+				t.Fatalf("base filter has %d clauses, want query, status and company", n)
+			}
+			if got := query["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"]; !reflect.DeepEqual(got, wantMust) {
+				t.Errorf("query must = %v, want %v", got, wantMust)
+			}
+
+			if tt.post == nil {
+				if pf, ok := query["post_filter"]; ok {
+					t.Errorf("post_filter = %v, want none", pf)
+				}
+			} else if got := boolMust(t, query["post_filter"]); !reflect.DeepEqual(got, tt.post) {
+				t.Errorf("post_filter must = %v, want %v", got, tt.post)
+			}
+
+			aggs := query["aggs"].(map[string]interface{})
+			if len(aggs) != len(carFacets) {
+				t.Errorf("%d aggregations, want %d", len(aggs), len(carFacets))
+			}
+			for name, want := range tt.aggs {
+				agg, ok := aggs[name].(map[string]interface{})
+				if !ok {
+					t.Errorf("aggregation %s missing", name)
+					continue
+				}
+				if got := boolMust(t, agg["filter"]); !reflect.DeepEqual(got, want) {
+					t.Errorf("aggregation %s filter = %v, want %v", name, got, want)
+				}
+				if _, ok := agg["aggs"].(map[string]interface{})[name]; !ok {
+					t.Errorf("aggregation %s has no inner %s aggregation", name, name)
+				}
+			}
+		})
+	}
+}
+
+// boolMust returns the must clauses of a bool query.
+func boolMust(t *testing.T, q interface{}) []map[string]interface{} {
+	t.Helper()
+	m, _ := q.(map[string]interface{})
+	b, _ := m["bool"].(map[string]interface{})
+	must, ok := b["must"].([]map[string]interface{})
+	if !ok {
+		t.Fatalf("%v is not a bool query with must clauses", q)
+	}
+	return must
+}
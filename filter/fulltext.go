@@ -0,0 +1,30 @@
+package filter
+
+// fullTextFields are the fields searched by the free-text Query of every
+// filter. Names are boosted over the description; the .text and .ru
+// subfields carry the language analyzers defined in the index mappings.
+var fullTextFields = []string{
+	"brand_name.text^3",
+	"model_name.text^3",
+	"description",
+	"description.ru",
+	"city_name_tm.text",
+	"city_name_en.text",
+	"city_name_ru.text",
+	"body_name_tm.text",
+	"body_name_en.text",
+	"body_name_ru.text",
+}
+
+// fullTextClause builds the multi_match clause for a free-text query.
+func fullTextClause(query string) map[string]interface{} {
+	return map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"query":     query,
+			"fields":    fullTextFields,
+			"type":      "best_fields",
+			"operator":  "and",
+			"fuzziness": "AUTO",
+		},
+	}
+}
@@ -1,10 +1,7 @@
 package filter
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
@@ -12,6 +9,7 @@ import (
 )
 
 type MotoFilter struct {
+	Query               string // free-text search over descriptions and names
 	BrandID             []int64
 	ModelID             []int64
 	BodyID              []int64
@@ -42,54 +40,72 @@ type MotoFilter struct {
 	CreatedAtMax        time.Time
 	IsCompany           *bool
 	IsPrivate           *bool
+	Facets              bool // return facet counts with the results
 }
 
-func SearchMotos(client *elasticsearch.Client, index string, filter *MotoFilter) ([]models.Moto, error) {
-	query := buildMotoESQuery(filter)
+func SearchMotos(client *elasticsearch.Client, index string, filter *MotoFilter) (*SearchResult[models.Moto], error) {
+	return SearchMotosContext(context.Background(), client, index, filter)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, fmt.Errorf("error encoding query: %s", err)
-	}
+// SearchMotosContext returns the motos in index matching filter.
+// If filter.Facets is set the result also carries the sidebar facet counts.
+func SearchMotosContext(ctx context.Context, client *elasticsearch.Client, index string, filter *MotoFilter) (*SearchResult[models.Moto], error) {
+	ctx, span := startSearchSpan(ctx, "SearchMotos", "moto", index, filter)
+	defer span.End()
 
-	res, err := client.Search(
-		client.Search.WithContext(context.Background()),
-		client.Search.WithIndex(index),
-		client.Search.WithBody(&buf),
-		client.Search.WithTrackTotalHits(true),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error getting response: %s", err)
+	var facets []facet
+	if filter.Facets {
+		facets = motoFacets
 	}
-	defer res.Body.Close()
+	return search[models.Moto](ctx, client, index, buildMotoESQuery(filter), facets)
+}
 
-	if res.IsError() {
-		return nil, fmt.Errorf("error response: %s", res.String())
-	}
+// SearchMotosAfter returns the page of motos following cursor, using a
+// point-in-time so pages stay consistent while listings are indexed.
+// Pass an empty cursor for the first page and the returned Cursor for the
+// next one; filter.Page is ignored.
+func SearchMotosAfter(ctx context.Context, client *elasticsearch.Client, index string, filter *MotoFilter, cursor string) (*SearchResult[models.Moto], error) {
+	ctx, span := startSearchSpan(ctx, "SearchMotosAfter", "moto", index, filter)
+	defer span.End()
 
-	var r struct {
-		Hits struct {
-			Hits []struct {
-				Source models.Moto `json:"_source"`
-			} `json:"hits"`
-		} `json:"hits"`
+	var facets []facet
+	if filter.Facets {
+		facets = motoFacets
 	}
+	return searchAfter[models.Moto](ctx, client, index, buildMotoESQuery(filter), facets, cursor)
+}
 
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, fmt.Errorf("error parsing response body: %s", err)
-	}
+// CountMotos returns the number of motos in index matching filter, e.g.
+// for a "show N results" button. Paging, sorting and facets are ignored.
+func CountMotos(ctx context.Context, client *elasticsearch.Client, index string, filter *MotoFilter) (int64, error) {
+	ctx, span := startSearchSpan(ctx, "CountMotos", "moto", index, filter)
+	defer span.End()
 
-	motosList := make([]models.Moto, len(r.Hits.Hits))
-	for i, hit := range r.Hits.Hits {
-		motosList[i] = hit.Source
-	}
+	return count(ctx, client, index, buildMotoESQuery(filter))
+}
 
-	return motosList, nil
+// MultiCountMotos returns the number of motos matching each of filters,
+// in order, using a single multi search request.
+func MultiCountMotos(ctx context.Context, client *elasticsearch.Client, index string, filters []*MotoFilter) ([]int64, error) {
+	ctx, span := startSearchSpan(ctx, "MultiCountMotos", "moto", index, nil)
+	defer span.End()
+
+	queries := make([]map[string]interface{}, len(filters))
+	for i, filter := range filters {
+		queries[i] = buildMotoESQuery(filter)
+	}
+	return countMany(ctx, client, index, queries)
 }
 
+var motoFacets = []facet{brandFacet, modelFacet, cityFacet, colorFacet, engineTypeFacet, bodyFacet, priceFacet, yearFacet, mileageFacet}
+
 func buildMotoESQuery(filter *MotoFilter) map[string]interface{} {
 	must := []map[string]interface{}{}
 
+	if filter.Query != "" {
+		must = append(must, fullTextClause(filter.Query))
+	}
+
 	if len(filter.BrandID) > 0 {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"brand_id": filter.BrandID}})
 	}
@@ -161,13 +177,13 @@ func buildMotoESQuery(filter *MotoFilter) map[string]interface{} {
 		must = append(must, map[string]interface{}{"term": map[string]interface{}{"is_credit": *filter.IsCredit}})
 	}
 	if len(filter.Status) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"status.keyword": filter.Status}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"status": filter.Status}})
 	}
 	if len(filter.NumberOfClockCycles) > 0 {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"number_of_clock_cycles": filter.NumberOfClockCycles}})
 	}
 	if len(filter.AirType) > 0 {
-		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"air_type.keyword": filter.AirType}})
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"air_type": filter.AirType}})
 	}
 	if len(filter.Options) > 0 {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"options": filter.Options}})
@@ -243,17 +259,21 @@ func buildMotoESQuery(filter *MotoFilter) map[string]interface{} {
 	if filter.YearOrder != nil {
 		sort = append(sort, map[string]interface{}{"year": map[string]interface{}{"order": *filter.YearOrder}})
 	}
-	if len(sort) == 0 {
-		// default sort by created_at desc
+	if len(sort) == 0 && filter.Query == "" {
+		// default sort by created_at desc, full-text queries sort by relevance
 		sort = append(sort, map[string]interface{}{"created_at": map[string]interface{}{"order": "desc"}})
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"query": map[string]interface{}{
 			"bool": map[string]interface{}{"must": must},
 		},
 		"size": size,
 		"from": from,
-		"sort": sort,
 	}
+	if len(sort) > 0 {
+		result["sort"] = sort
+	}
+
+	return result
 }
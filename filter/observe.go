@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"context"
+	"reflect"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Hajymuhammet/elasticsearch-package/filter")
+
+// startSearchSpan starts the span of a Search* call. search and searchAfter
+// add the outcome to the span found in the context.
+func startSearchSpan(ctx context.Context, name, kind, index string, filter interface{}) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "filter."+name, trace.WithAttributes(
+		attribute.String("vehicle.type", kind),
+		attribute.String("elasticsearch.index", index),
+		attribute.StringSlice("search.filter_fields", filterFields(filter)),
+	))
+}
+
+// spanError marks the span in ctx as failed with err.
+func spanError(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// filterFields returns the names of the fields of a filter struct that are
+// set, i.e. not their zero value.
+func filterFields(filter interface{}) []string {
+	v := reflect.ValueOf(filter)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsZero() {
+			fields = append(fields, v.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
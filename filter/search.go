@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Hit is a single search hit together with its score and sort values.
+type Hit[T any] struct {
+	ID     string
+	Score  *float64
+	Sort   []interface{}
+	Source T
+}
+
+// SearchResult is one page of search results with pagination metadata.
+type SearchResult[T any] struct {
+	Items         []T
+	Hits          []Hit[T]
+	Total         int64
+	TotalRelation string // "eq" or "gte"
+	Page          int
+	PageSize      int
+	TotalPages    int
+	TookMs        int64
+	Aggregations  map[string]json.RawMessage
+	Facets        map[string][]FacetBucket // set when the filter requests facets
+	Cursor        string                   // next page token for the Search*After functions, empty on the last page
+
+	pitID string
+}
+
+// search runs query against index and decodes the response into a SearchResult.
+// When facets is non-empty the facet aggregations are added and parsed as well.
+// Point-in-time queries, which carry a "pit" clause, are not sent to index
+// since the point-in-time already names it.
+func search[T any](ctx context.Context, client *elasticsearch.Client, index string, query map[string]interface{}, facets []facet) (_ *SearchResult[T], err error) {
+	start := time.Now()
+	defer func() {
+		metrics.Get().ObserveOperation("search", index, time.Since(start), err)
+		if err != nil {
+			spanError(ctx, err)
+		}
+	}()
+
+	if len(facets) > 0 {
+		applyFacets(query, facets)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, fmt.Errorf("error encoding query: %w", err)
+	}
+
+	opts := []func(*esapi.SearchRequest){
+		client.Search.WithContext(ctx),
+		client.Search.WithBody(&buf),
+		client.Search.WithTrackTotalHits(true),
+	}
+	if _, pit := query["pit"]; !pit {
+		opts = append(opts, client.Search.WithIndex(index))
+	}
+
+	res, err := client.Search(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting response: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error response: %w", eserrors.FromResponse(res))
+	}
+
+	var r struct {
+		Took  int64  `json:"took"`
+		PitID string `json:"pit_id"`
+		Hits  struct {
+			Total struct {
+				Value    int64  `json:"value"`
+				Relation string `json:"relation"`
+			} `json:"total"`
+			Hits []struct {
+				ID     string          `json:"_id"`
+				Score  *float64        `json:"_score"`
+				Sort   json.RawMessage `json:"sort"`
+				Source T               `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing response body: %w", err)
+	}
+
+	size, _ := query["size"].(int)
+	from, _ := query["from"].(int)
+
+	result := &SearchResult[T]{
+		Items:         make([]T, len(r.Hits.Hits)),
+		Hits:          make([]Hit[T], len(r.Hits.Hits)),
+		Total:         r.Hits.Total.Value,
+		TotalRelation: r.Hits.Total.Relation,
+		PageSize:      size,
+		TookMs:        r.Took,
+		Aggregations:  r.Aggregations,
+		pitID:         r.PitID,
+	}
+	for i, hit := range r.Hits.Hits {
+		result.Items[i] = hit.Source
+		result.Hits[i] = Hit[T]{ID: hit.ID, Score: hit.Score, Source: hit.Source}
+		if len(hit.Sort) > 0 {
+			// Keep sort values as json.Number so long values such as
+			// _shard_doc survive the round trip through search_after.
+			dec := json.NewDecoder(bytes.NewReader(hit.Sort))
+			dec.UseNumber()
+			if err := dec.Decode(&result.Hits[i].Sort); err != nil {
+				return nil, fmt.Errorf("error parsing sort values: %w", err)
+			}
+		}
+	}
+	if len(facets) > 0 {
+		if result.Facets, err = parseFacets(r.Aggregations, facets); err != nil {
+			return nil, err
+		}
+	}
+	metrics.Get().ObserveHits(index, len(result.Hits))
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("search.hits", len(result.Hits)),
+		attribute.Int64("search.total", result.Total),
+		attribute.Int64("search.took_ms", result.TookMs),
+	)
+	if size > 0 {
+		result.Page = from/size + 1
+		result.TotalPages = int((result.Total + int64(size) - 1) / int64(size))
+	}
+	return result, nil
+}
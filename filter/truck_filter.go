@@ -1,10 +1,7 @@
 package filter
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
@@ -12,6 +9,7 @@ import (
 )
 
 type TruckFilter struct {
+	Query              string // free-text search over descriptions and names
 	BrandID            []int64
 	ModelID            []int64
 	BodyID             []int64
@@ -62,74 +60,92 @@ type TruckFilter struct {
 	Page               *int
 	IsCompany          *bool
 	IsPrivate          *bool
+	Facets             bool // return facet counts with the results
 }
 
-func SearchTrucks(client *elasticsearch.Client, index string, filter *TruckFilter) ([]models.Truck, error) {
-	query := buildTruckESQuery(filter)
+func SearchTrucks(client *elasticsearch.Client, index string, filter *TruckFilter) (*SearchResult[models.Truck], error) {
+	return SearchTrucksContext(context.Background(), client, index, filter)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, fmt.Errorf("error encoding query: %s", err)
-	}
+// SearchTrucksContext returns the trucks in index matching filter.
+// If filter.Facets is set the result also carries the sidebar facet counts.
+func SearchTrucksContext(ctx context.Context, client *elasticsearch.Client, index string, filter *TruckFilter) (*SearchResult[models.Truck], error) {
+	ctx, span := startSearchSpan(ctx, "SearchTrucks", "truck", index, filter)
+	defer span.End()
 
-	res, err := client.Search(
-		client.Search.WithContext(context.Background()),
-		client.Search.WithIndex(index),
-		client.Search.WithBody(&buf),
-		client.Search.WithTrackTotalHits(true),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error getting response: %s", err)
+	var facets []facet
+	if filter.Facets {
+		facets = truckFacets
 	}
-	defer res.Body.Close()
+	return search[models.Truck](ctx, client, index, buildTruckESQuery(filter), facets)
+}
 
-	if res.IsError() {
-		return nil, fmt.Errorf("error response: %s", res.String())
-	}
+// SearchTrucksAfter returns the page of trucks following cursor, using a
+// point-in-time so pages stay consistent while listings are indexed.
+// Pass an empty cursor for the first page and the returned Cursor for the
+// next one; filter.Page is ignored.
+func SearchTrucksAfter(ctx context.Context, client *elasticsearch.Client, index string, filter *TruckFilter, cursor string) (*SearchResult[models.Truck], error) {
+	ctx, span := startSearchSpan(ctx, "SearchTrucksAfter", "truck", index, filter)
+	defer span.End()
 
-	var r struct {
-		Hits struct {
-			Hits []struct {
-				Source models.Truck `json:"_source"`
-			} `json:"hits"`
-		} `json:"hits"`
+	var facets []facet
+	if filter.Facets {
+		facets = truckFacets
 	}
+	return searchAfter[models.Truck](ctx, client, index, buildTruckESQuery(filter), facets, cursor)
+}
 
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, fmt.Errorf("error parsing response body: %s", err)
-	}
+// CountTrucks returns the number of trucks in index matching filter, e.g.
+// for a "show N results" button. Paging, sorting and facets are ignored.
+func CountTrucks(ctx context.Context, client *elasticsearch.Client, index string, filter *TruckFilter) (int64, error) {
+	ctx, span := startSearchSpan(ctx, "CountTrucks", "truck", index, filter)
+	defer span.End()
 
-	trucksList := make([]models.Truck, len(r.Hits.Hits))
-	for i, hit := range r.Hits.Hits {
-		trucksList[i] = hit.Source
-	}
+	return count(ctx, client, index, buildTruckESQuery(filter))
+}
+
+// MultiCountTrucks returns the number of trucks matching each of filters,
+// in order, using a single multi search request.
+func MultiCountTrucks(ctx context.Context, client *elasticsearch.Client, index string, filters []*TruckFilter) ([]int64, error) {
+	ctx, span := startSearchSpan(ctx, "MultiCountTrucks", "truck", index, nil)
+	defer span.End()
 
-	return trucksList, nil
+	queries := make([]map[string]interface{}, len(filters))
+	for i, filter := range filters {
+		queries[i] = buildTruckESQuery(filter)
+	}
+	return countMany(ctx, client, index, queries)
 }
 
+var truckFacets = []facet{brandFacet, modelFacet, cityFacet, colorFacet, engineTypeFacet, transmissionFacet, bodyFacet, priceFacet, yearFacet, mileageFacet}
+
 func buildTruckESQuery(filter *TruckFilter) map[string]interface{} {
 	must := []map[string]interface{}{}
 
+	if filter.Query != "" {
+		must = append(must, fullTextClause(filter.Query))
+	}
+
 	// Terms filters
 	termsFilters := map[string][]string{
-		"load_capacity":           filter.LoadCapacity,
-		"engine_type":             filter.EngineType,
-		"transmission":            filter.Transmission,
-		"drive_type":              filter.DriveType,
-		"color":                   filter.Color,
-		"body_type.keyword":       filter.BodyType,
-		"cab_type.keyword":        filter.CabType,
-		"wheel_formula.keyword":   filter.WheelFormula,
-		"brakes.keyword":          filter.Brakes,
-		"vehicle_type.keyword":    filter.VehicleType,
-		"forklift_type.keyword":   filter.ForkliftType,
-		"cab_suspension.keyword":  filter.CabSuspension,
-		"suspension_type.keyword": filter.SuspensionType,
-		"status.keyword":          filter.Status,
-		"chassis.keyword":         filter.Chassis,
-		"bus_type.keyword":        filter.BusType,
-		"excavator_type.keyword":  filter.ExcavatorType,
-		"bulldozer_type.keyword":  filter.BulldozerType,
+		"load_capacity":   filter.LoadCapacity,
+		"engine_type":     filter.EngineType,
+		"transmission":    filter.Transmission,
+		"drive_type":      filter.DriveType,
+		"color":           filter.Color,
+		"body_type":       filter.BodyType,
+		"cab_type":        filter.CabType,
+		"wheel_formula":   filter.WheelFormula,
+		"brakes":          filter.Brakes,
+		"vehicle_type":    filter.VehicleType,
+		"forklift_type":   filter.ForkliftType,
+		"cab_suspension":  filter.CabSuspension,
+		"suspension_type": filter.SuspensionType,
+		"status":          filter.Status,
+		"chassis":         filter.Chassis,
+		"bus_type":        filter.BusType,
+		"excavator_type":  filter.ExcavatorType,
+		"bulldozer_type":  filter.BulldozerType,
 	}
 
 	for field, values := range termsFilters {
@@ -154,7 +170,7 @@ func buildTruckESQuery(filter *TruckFilter) map[string]interface{} {
 		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"city_id": filter.CityID}})
 	}
 	if filter.Vin != nil {
-		must = append(must, map[string]interface{}{"term": map[string]interface{}{"vin.keyword": *filter.Vin}})
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"vin": *filter.Vin}})
 	}
 	if filter.IsExchange != nil {
 		must = append(must, map[string]interface{}{"term": map[string]interface{}{"is_exchange": *filter.IsExchange}})
@@ -0,0 +1,119 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+)
+
+// ErrBulkItemsFailed is wrapped by the error returned from bulk operations
+// in which at least one item was rejected. The BulkResult lists which.
+var ErrBulkItemsFailed = errors.New("bulk items failed")
+
+// BulkItemError describes a single bulk item rejected by Elasticsearch.
+type BulkItemError struct {
+	ID     int64
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e BulkItemError) Error() string {
+	return fmt.Sprintf("ID=%d: %s", e.ID, e.Unwrap())
+}
+
+// Unwrap returns the item failure as an *eserrors.Error, so errors.Is
+// matches it against the eserrors categories.
+func (e BulkItemError) Unwrap() error {
+	return eserrors.New(e.Status, e.Type, e.Reason)
+}
+
+// BulkResult is the per-item outcome of a bulk request.
+type BulkResult struct {
+	Succeeded []int64
+	Failed    []BulkItemError
+}
+
+// HasFailures reports whether any item of the bulk request failed.
+func (r *BulkResult) HasFailures() bool {
+	return len(r.Failed) > 0
+}
+
+// FailedIDs returns the IDs of the failed items, e.g. to retry them.
+func (r *BulkResult) FailedIDs() []int64 {
+	ids := make([]int64, len(r.Failed))
+	for i, f := range r.Failed {
+		ids[i] = f.ID
+	}
+	return ids
+}
+
+// err returns a non-nil error wrapping ErrBulkItemsFailed and the first
+// failed item if any item failed.
+func (r *BulkResult) err(op, kind string) error {
+	if !r.HasFailures() {
+		return nil
+	}
+	first := r.Failed[0]
+	return fmt.Errorf("%w: bulk %s of %s documents: %d of %d items failed, first %w",
+		ErrBulkItemsFailed, op, kind, len(r.Failed), len(r.Failed)+len(r.Succeeded), first)
+}
+
+// bulkItem is the outcome of one item of a bulk request, in request order.
+type bulkItem struct {
+	Action string
+	ID     int64
+	Status int
+	Error  *BulkItemError
+}
+
+// parseBulkItems decodes the items of a bulk response body in request order.
+func parseBulkItems(body io.Reader) ([]bulkItem, error) {
+	var r struct {
+		Items []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing bulk response body: %w", err)
+	}
+
+	items := make([]bulkItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		for action, op := range item {
+			id, _ := strconv.ParseInt(op.ID, 10, 64)
+			it := bulkItem{Action: action, ID: id, Status: op.Status}
+			if op.Error != nil || op.Status >= 300 {
+				it.Error = &BulkItemError{ID: id, Status: op.Status}
+				if op.Error != nil {
+					it.Error.Type = op.Error.Type
+					it.Error.Reason = op.Error.Reason
+				}
+			}
+			items = append(items, it)
+		}
+	}
+	return items, nil
+}
+
+// newBulkResult collects the item outcomes of a bulk request.
+func newBulkResult(items []bulkItem) *BulkResult {
+	result := &BulkResult{}
+	for _, it := range items {
+		if it.Error != nil {
+			result.Failed = append(result.Failed, *it.Error)
+		} else {
+			result.Succeeded = append(result.Succeeded, it.ID)
+		}
+	}
+	return result
+}
@@ -0,0 +1,425 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrBulkIndexerClosed is returned by Add after Close has been called.
+var ErrBulkIndexerClosed = errors.New("bulk indexer is closed")
+
+// BulkAction is the kind of a bulk operation.
+type BulkAction string
+
+const (
+	ActionIndex  BulkAction = "index"
+	ActionUpdate BulkAction = "update"
+	ActionDelete BulkAction = "delete"
+)
+
+// BulkOp is a single operation queued on a BulkIndexer.
+type BulkOp struct {
+	Action   BulkAction
+	Index    string // defaults to BulkIndexerConfig.Index
+	ID       int64
+	Document interface{} // required for index and update
+	Upsert   bool        // for update: create the document if it is missing
+
+	// IfVersion applies the operation only to this revision of the stored
+	// document. ExternalVersion, for index and delete, applies it only if
+	// greater than the stored external version. An operation finding the
+	// same version counts as succeeded if the stored document is identical
+	// or, for a delete, gone, e.g. after a retry; otherwise it is a conflict.
+	IfVersion       *Version
+	ExternalVersion int64
+
+	// OnSuccess and OnFailure override the indexer-wide callbacks.
+	OnSuccess func(ctx context.Context, op BulkOp)
+	OnFailure func(ctx context.Context, op BulkOp, item BulkItemError, err error)
+}
+
+// IndexOp returns an operation storing doc in index.
+func IndexOp(index string, doc Document) BulkOp {
+	return BulkOp{Action: ActionIndex, Index: index, ID: doc.DocumentID(), Document: doc}
+}
+
+// UpdateOp returns an operation merging doc into the stored document.
+func UpdateOp(index string, doc Document) BulkOp {
+	return BulkOp{Action: ActionUpdate, Index: index, ID: doc.DocumentID(), Document: doc}
+}
+
+// DeleteOp returns an operation removing the document with the given ID.
+func DeleteOp(index string, id int64) BulkOp {
+	return BulkOp{Action: ActionDelete, Index: index, ID: id}
+}
+
+// BulkIndexerConfig configures a BulkIndexer. Zero values use the defaults.
+type BulkIndexerConfig struct {
+	Index         string        // default index for operations without one
+	NumWorkers    int           // defaults to runtime.NumCPU()
+	FlushBytes    int           // defaults to 5MB
+	FlushCount    int           // defaults to 1000 operations
+	FlushInterval time.Duration // defaults to 30s
+	Refresh       string        // "", "true", "false" or "wait_for"
+	Retry         *BulkRetry    // defaults to DefaultBulkRetry
+
+	OnSuccess    func(ctx context.Context, op BulkOp)
+	OnFailure    func(ctx context.Context, op BulkOp, item BulkItemError, err error)
+	OnFlushError func(err error) // called when a whole bulk request fails
+
+	Logger *slog.Logger // receives flush outcomes; nil discards them
+}
+
+// BulkIndexerStats are the aggregate counters of a BulkIndexer.
+type BulkIndexerStats struct {
+	NumAdded    uint64
+	NumFlushed  uint64
+	NumFailed   uint64
+	NumIndexed  uint64
+	NumUpdated  uint64
+	NumDeleted  uint64
+	NumRequests uint64
+}
+
+// BulkIndexer batches operations of any vehicle kind into bulk requests
+// executed by a pool of workers. Each worker flushes its batch when it
+// reaches FlushBytes or FlushCount, and at every FlushInterval.
+type BulkIndexer struct {
+	client *elasticsearch.Client
+	cfg    BulkIndexerConfig
+	queue  chan bulkEntry
+	wg     sync.WaitGroup
+
+	// ctx is the base context of the flushes. Close cancels it when its
+	// own context is done first, aborting the requests and retries in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Add holds mu only to register in adding, never while it blocks on
+	// queue; closing wakes up the blocked Add calls, and the queue is closed
+	// once all of them have returned.
+	mu      sync.RWMutex
+	closed  bool
+	adding  sync.WaitGroup
+	closing chan struct{}
+	done    chan struct{} // closed once the workers have exited
+
+	numAdded, numFlushed, numFailed                atomic.Uint64
+	numIndexed, numUpdated, numDeleted, numRequest atomic.Uint64
+}
+
+type bulkEntry struct {
+	ctx  context.Context
+	op   BulkOp
+	data []byte
+}
+
+// NewBulkIndexer starts a BulkIndexer. Call Close to flush and stop it.
+func NewBulkIndexer(client *elasticsearch.Client, cfg BulkIndexerConfig) *BulkIndexer {
+	if cfg.NumWorkers <= 0 {
+		cfg.NumWorkers = runtime.NumCPU()
+	}
+	if cfg.FlushBytes <= 0 {
+		cfg.FlushBytes = 5 << 20
+	}
+	if cfg.FlushCount <= 0 {
+		cfg.FlushCount = 1000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 30 * time.Second
+	}
+	if cfg.Retry == nil {
+		retry := DefaultBulkRetry
+		cfg.Retry = &retry
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardLogger
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BulkIndexer{
+		client:  client,
+		cfg:     cfg,
+		queue:   make(chan bulkEntry, cfg.NumWorkers),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	b.wg.Add(cfg.NumWorkers)
+	for i := 0; i < cfg.NumWorkers; i++ {
+		go b.worker()
+	}
+	return b
+}
+
+// Add queues op. It blocks while all workers are busy, until ctx is done or
+// Close is called. ctx is also passed to the op callbacks.
+func (b *BulkIndexer) Add(ctx context.Context, op BulkOp) error {
+	if op.Index == "" {
+		op.Index = b.cfg.Index
+	}
+	data, err := encodeBulkOp(op)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBulkIndexerClosed
+	}
+	b.adding.Add(1)
+	b.mu.RUnlock()
+	defer b.adding.Done()
+
+	select {
+	case b.queue <- bulkEntry{ctx: ctx, op: op, data: data}:
+		b.numAdded.Add(1)
+		return nil
+	case <-b.closing:
+		return ErrBulkIndexerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the queued operations and stops the workers. Add calls
+// blocked on a full queue return ErrBulkIndexerClosed. If ctx is done before
+// the workers finish, the flushes in flight are cancelled, their operations
+// reported as failed, and Close returns ctx.Err() once the workers have
+// exited; no callback runs after Close returns.
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.closing)
+		go func() {
+			b.adding.Wait()
+			close(b.queue)
+			b.wg.Wait()
+			close(b.done)
+		}()
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the indexer counters.
+func (b *BulkIndexer) Stats() BulkIndexerStats {
+	return BulkIndexerStats{
+		NumAdded:    b.numAdded.Load(),
+		NumFlushed:  b.numFlushed.Load(),
+		NumFailed:   b.numFailed.Load(),
+		NumIndexed:  b.numIndexed.Load(),
+		NumUpdated:  b.numUpdated.Load(),
+		NumDeleted:  b.numDeleted.Load(),
+		NumRequests: b.numRequest.Load(),
+	}
+}
+
+func (b *BulkIndexer) worker() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var (
+		batch []bulkEntry
+		size  int
+	)
+	flush := func() {
+		if len(batch) > 0 {
+			b.flush(batch)
+		}
+		batch, size = nil, 0
+	}
+
+	for {
+		select {
+		case entry, ok := <-b.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			size += len(entry.data)
+			if size >= b.cfg.FlushBytes || len(batch) >= b.cfg.FlushCount {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (b *BulkIndexer) flush(batch []bulkEntry) {
+	chunks := make([][]byte, len(batch))
+	for i, entry := range batch {
+		chunks[i] = entry.data
+	}
+
+	var opts []func(*esapi.BulkRequest)
+	if b.cfg.Refresh != "" {
+		opts = append(opts, b.client.Bulk.WithRefresh(b.cfg.Refresh))
+	}
+
+	// The flush outlives the Add calls of its operations, so their spans
+	// are linked rather than used as parents.
+	ctx, op := startOperation(b.ctx, "bulk_flush", "", b.cfg.Index, attribute.Int("elasticsearch.doc_count", len(batch)))
+	linked := map[trace.SpanID]bool{}
+	for _, entry := range batch {
+		if sc := trace.SpanContextFromContext(entry.ctx); sc.IsValid() && !linked[sc.SpanID()] {
+			linked[sc.SpanID()] = true
+			op.span.AddLink(trace.Link{SpanContext: sc})
+		}
+	}
+
+	b.numRequest.Add(1)
+	items, err := sendBulk(ctx, b.client, chunks, *b.cfg.Retry, opts...)
+	op.end(err)
+	if err != nil {
+		for index, n := range countByIndex(batch, nil) {
+			metrics.Get().AddBulkItems(index, 0, n)
+		}
+		b.cfg.Logger.Warn("bulk flush failed", "index", b.cfg.Index, "count", len(batch), "took", op.took(), "error", err)
+		if b.cfg.OnFlushError != nil {
+			b.cfg.OnFlushError(err)
+		}
+		for _, entry := range batch {
+			b.fail(entry, BulkItemError{ID: entry.op.ID}, err)
+		}
+		return
+	}
+	b.cfg.Logger.Debug("flushed bulk batch", "index", b.cfg.Index, "count", len(batch), "took", op.took())
+
+	writes := make([]versionedWrite, len(batch))
+	for i, entry := range batch {
+		writes[i] = versionedWrite{Index: entry.op.Index, Version: entry.op.ExternalVersion}
+		if _, source, ok := bytes.Cut(entry.data, []byte("\n")); ok && len(source) > 0 {
+			writes[i].Source = source
+		}
+	}
+	if err := acceptRepeatedWrites(ctx, b.client, items, writes); err != nil {
+		b.cfg.Logger.Warn("error comparing documents at version", "index", b.cfg.Index, "error", err)
+	}
+	failed := countByIndex(batch, func(i int) bool { return items[i].Error != nil })
+	for index, n := range countByIndex(batch, nil) {
+		metrics.Get().AddBulkItems(index, n-failed[index], failed[index])
+	}
+
+	for i, item := range items {
+		entry := batch[i]
+		if item.Error != nil {
+			b.fail(entry, *item.Error, nil)
+			continue
+		}
+
+		b.numFlushed.Add(1)
+		switch entry.op.Action {
+		case ActionIndex:
+			b.numIndexed.Add(1)
+		case ActionUpdate:
+			b.numUpdated.Add(1)
+		case ActionDelete:
+			b.numDeleted.Add(1)
+		}
+
+		switch {
+		case entry.op.OnSuccess != nil:
+			entry.op.OnSuccess(entry.ctx, entry.op)
+		case b.cfg.OnSuccess != nil:
+			b.cfg.OnSuccess(entry.ctx, entry.op)
+		}
+	}
+}
+
+func (b *BulkIndexer) fail(entry bulkEntry, item BulkItemError, err error) {
+	b.numFailed.Add(1)
+	switch {
+	case entry.op.OnFailure != nil:
+		entry.op.OnFailure(entry.ctx, entry.op, item, err)
+	case b.cfg.OnFailure != nil:
+		b.cfg.OnFailure(entry.ctx, entry.op, item, err)
+	}
+}
+
+// countByIndex counts the entries of batch per target index, only those
+// for which match returns true if it is not nil.
+func countByIndex(batch []bulkEntry, match func(i int) bool) map[string]int {
+	counts := map[string]int{}
+	for i, entry := range batch {
+		if match == nil || match(i) {
+			counts[entry.op.Index]++
+		}
+	}
+	return counts
+}
+
+// encodeBulkOp renders the action line and, for index and update, the
+// source line of op.
+func encodeBulkOp(op BulkOp) ([]byte, error) {
+	if op.Index == "" {
+		return nil, fmt.Errorf("bulk %s ID=%d: no index", op.Action, op.ID)
+	}
+
+	switch {
+	case op.IfVersion != nil && op.ExternalVersion > 0:
+		return nil, fmt.Errorf("bulk %s ID=%d: both IfVersion and ExternalVersion set", op.Action, op.ID)
+	case op.Action == ActionUpdate && op.ExternalVersion > 0:
+		return nil, fmt.Errorf("bulk update ID=%d: updates do not support external versions", op.ID)
+	}
+
+	meta, err := actionLine(op.Action, op.Index, op.ID, op.IfVersion, op.ExternalVersion)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(meta)
+
+	var source interface{}
+	switch op.Action {
+	case ActionIndex:
+		source = op.Document
+	case ActionUpdate:
+		source = map[string]interface{}{"doc": op.Document, "doc_as_upsert": op.Upsert}
+	case ActionDelete:
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown bulk action %q", op.Action)
+	}
+	if op.Document == nil {
+		return nil, fmt.Errorf("bulk %s ID=%d: no document", op.Action, op.ID)
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling bulk %s ID=%d: %w", op.Action, op.ID, err)
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
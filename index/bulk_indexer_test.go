@@ -0,0 +1,231 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newTestClient returns a client sending its requests to handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// bulkAction is an action line of a bulk request body.
+type bulkAction struct {
+	Action string
+	Index  string
+	ID     string
+}
+
+// readBulkActions returns the action lines of a bulk request body.
+func readBulkActions(t *testing.T, body io.Reader) []bulkAction {
+	t.Helper()
+	var actions []bulkAction
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var line map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Errorf("invalid bulk line %q: %v", scanner.Text(), err)
+			continue
+		}
+		for action, meta := range line {
+			actions = append(actions, bulkAction{Action: action, Index: meta.Index, ID: meta.ID})
+		}
+		if _, ok := line["delete"]; !ok && scanner.Scan() {
+			continue // skip the source line
+		}
+	}
+	return actions
+}
+
+// bulkResponse answers each action with the status from statuses by ID,
+// 200 if not listed.
+func bulkResponse(actions []bulkAction, statuses map[string]int) string {
+	items := make([]string, len(actions))
+	failed := false
+	for i, a := range actions {
+		status := http.StatusOK
+		if s, ok := statuses[a.ID]; ok {
+			status = s
+		}
+		if status >= 300 {
+			failed = true
+			items[i] = fmt.Sprintf(`{%q:{"_index":%q,"_id":%q,"status":%d,"error":{"type":"es_rejected_execution_exception","reason":"rejected"}}}`, a.Action, a.Index, a.ID, status)
+		} else {
+			items[i] = fmt.Sprintf(`{%q:{"_index":%q,"_id":%q,"status":%d}}`, a.Action, a.Index, a.ID, status)
+		}
+	}
+	return fmt.Sprintf(`{"took":1,"errors":%t,"items":[%s]}`, failed, strings.Join(items, ","))
+}
+
+func TestBulkIndexerFlushesOnClose(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []bulkAction
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		actions := readBulkActions(t, r.Body)
+		mu.Lock()
+		sent = append(sent, actions...)
+		mu.Unlock()
+		io.WriteString(w, bulkResponse(actions, map[string]int{"3": http.StatusBadRequest}))
+	})
+
+	var succeeded, failed []int64
+	bi := NewBulkIndexer(client, BulkIndexerConfig{
+		Index:      "cars",
+		NumWorkers: 2,
+		OnSuccess: func(ctx context.Context, op BulkOp) {
+			mu.Lock()
+			succeeded = append(succeeded, op.ID)
+			mu.Unlock()
+		},
+		OnFailure: func(ctx context.Context, op BulkOp, item BulkItemError, err error) {
+			mu.Lock()
+			failed = append(failed, op.ID)
+			mu.Unlock()
+		},
+	})
+	for _, op := range []BulkOp{DeleteOp("", 1), DeleteOp("motos", 2), DeleteOp("", 3)} {
+		if err := bi.Add(context.Background(), op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 3 || len(succeeded) != 2 || len(failed) != 1 || failed[0] != 3 {
+		t.Errorf("sent %v, succeeded %v, failed %v", sent, succeeded, failed)
+	}
+	for _, a := range sent {
+		if want := map[string]string{"1": "cars", "2": "motos", "3": "cars"}[a.ID]; a.Index != want {
+			t.Errorf("ID %s sent to %q, want %q", a.ID, a.Index, want)
+		}
+	}
+	stats := bi.Stats()
+	if stats.NumAdded != 3 || stats.NumFlushed != 2 || stats.NumFailed != 1 || stats.NumDeleted != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if err := bi.Add(context.Background(), DeleteOp("", 4)); !errors.Is(err, ErrBulkIndexerClosed) {
+		t.Errorf("Add after Close error = %v, want ErrBulkIndexerClosed", err)
+	}
+}
+
+func TestBulkIndexerCloseDeadlineWithBlockedAdd(t *testing.T) {
+	received := make(chan struct{}, 1)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // lets the server notice the client hanging up
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		// A stuck flush: answer only once the client gives up.
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	var (
+		mu        sync.Mutex
+		failures  []error
+		afterStop bool
+		stopped   bool
+	)
+	bi := NewBulkIndexer(client, BulkIndexerConfig{
+		Index:      "cars",
+		NumWorkers: 1,
+		FlushCount: 1,
+		Retry:      &BulkRetry{},
+		OnFailure: func(ctx context.Context, op BulkOp, item BulkItemError, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, err)
+			afterStop = afterStop || stopped
+		},
+	})
+
+	// The worker is stuck flushing ID 1 and ID 2 fills the queue, so
+	// adding ID 3 blocks.
+	if err := bi.Add(context.Background(), DeleteOp("", 1)); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+	if err := bi.Add(context.Background(), DeleteOp("", 2)); err != nil {
+		t.Fatal(err)
+	}
+	added := make(chan error)
+	go func() { added <- bi.Add(context.Background(), DeleteOp("", 3)) }()
+	select {
+	case err := <-added:
+		t.Fatalf("Add returned %v while the queue is full", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := bi.Close(ctx)
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close error = %v, want context.DeadlineExceeded", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("Close took %s despite its deadline", took)
+	}
+	select {
+	case err := <-added:
+		if !errors.Is(err, ErrBulkIndexerClosed) {
+			t.Errorf("blocked Add error = %v, want ErrBulkIndexerClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Add did not return after Close")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) != 2 {
+		t.Errorf("%d operations failed, want 2: %v", len(failures), failures)
+	}
+	for _, err := range failures {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("failure error = %v, want context.Canceled", err)
+		}
+	}
+	if afterStop {
+		t.Error("OnFailure called after Close returned")
+	}
+}
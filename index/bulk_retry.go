@@ -0,0 +1,97 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// BulkRetry controls how bulk items rejected with 429 Too Many Requests are
+// re-sent. Only the rejected items are sent again; items that succeeded or
+// failed for another reason keep their first outcome.
+type BulkRetry struct {
+	MaxRetries int
+	Backoff    func(attempt int) time.Duration // delay before retry number attempt; nil means none
+}
+
+// DefaultBulkRetry is used by Repository bulk operations and by a BulkIndexer
+// without BulkIndexerConfig.Retry. clients.RetryConfig.Backoff can be used
+// as Backoff to share the client policy.
+var DefaultBulkRetry = BulkRetry{
+	MaxRetries: 3,
+	Backoff: func(attempt int) time.Duration {
+		return time.Duration(1<<min(attempt-1, 6)) * 100 * time.Millisecond
+	},
+}
+
+// sendBulk sends the NDJSON chunks, one per operation, as a bulk request and
+// returns the item outcomes in chunk order. Items rejected with 429 are
+// re-sent according to retry; opts must not set a context.
+func sendBulk(ctx context.Context, client *elasticsearch.Client, chunks [][]byte, retry BulkRetry, opts ...func(*esapi.BulkRequest)) ([]bulkItem, error) {
+	results := make([]bulkItem, len(chunks))
+	pending := make([]int, len(chunks))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for attempt := 0; ; attempt++ {
+		var buf bytes.Buffer
+		for _, i := range pending {
+			buf.Write(chunks[i])
+		}
+
+		res, err := client.Bulk(
+			bytes.NewReader(buf.Bytes()),
+			append([]func(*esapi.BulkRequest){client.Bulk.WithContext(ctx)}, opts...)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items, err := func() ([]bulkItem, error) {
+			defer res.Body.Close()
+			if res.IsError() {
+				return nil, fmt.Errorf("bulk request error: %w", eserrors.FromResponse(res))
+			}
+			return parseBulkItems(res.Body)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != len(pending) {
+			return nil, fmt.Errorf("bulk response has %d items, expected %d", len(items), len(pending))
+		}
+
+		var rejected []int
+		for j, item := range items {
+			i := pending[j]
+			results[i] = item
+			if item.Status == http.StatusTooManyRequests && attempt < retry.MaxRetries {
+				rejected = append(rejected, i)
+			}
+		}
+		if len(rejected) == 0 {
+			return results, nil
+		}
+		pending = rejected
+		for range rejected {
+			metrics.Get().IncRetry("bulk_item")
+		}
+
+		if retry.Backoff != nil {
+			timer := time.NewTimer(retry.Backoff(attempt + 1))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bulkServer answers each bulk request with statuses[n] by document ID for
+// request n, or 200, and records the IDs sent with each request.
+type bulkServer struct {
+	mu       sync.Mutex
+	statuses []map[string]int
+	requests [][]string
+}
+
+func (s *bulkServer) handle(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actions := readBulkActions(t, r.Body)
+		s.mu.Lock()
+		n := len(s.requests)
+		ids := make([]string, len(actions))
+		for i, a := range actions {
+			ids[i] = a.ID
+		}
+		s.requests = append(s.requests, ids)
+		var statuses map[string]int
+		if n < len(s.statuses) {
+			statuses = s.statuses[n]
+		}
+		s.mu.Unlock()
+		io.WriteString(w, bulkResponse(actions, statuses))
+	}
+}
+
+func deleteChunks(t *testing.T, ids ...int64) [][]byte {
+	t.Helper()
+	chunks := make([][]byte, len(ids))
+	for i, id := range ids {
+		data, err := encodeBulkOp(DeleteOp("cars", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i] = data
+	}
+	return chunks
+}
+
+func TestSendBulkResendsRejectedItems(t *testing.T) {
+	tests := []struct {
+		name         string
+		retry        BulkRetry
+		statuses     []map[string]int
+		wantRequests [][]string
+		wantStatus   []int // per chunk, in chunk order
+	}{
+		{
+			name:         "rejected items accepted on retry",
+			retry:        BulkRetry{MaxRetries: 3},
+			statuses:     []map[string]int{{"2": 429, "4": 429, "5": 404}},
+			wantRequests: [][]string{{"1", "2", "3", "4", "5"}, {"2", "4"}},
+			wantStatus:   []int{200, 200, 200, 200, 404},
+		},
+		{
+			name:         "retried in steps",
+			retry:        BulkRetry{MaxRetries: 3},
+			statuses:     []map[string]int{{"1": 429, "3": 429, "5": 429}, {"3": 429}},
+			wantRequests: [][]string{{"1", "2", "3", "4", "5"}, {"1", "3", "5"}, {"3"}},
+			wantStatus:   []int{200, 200, 200, 200, 200},
+		},
+		{
+			name:         "rejected until MaxRetries",
+			retry:        BulkRetry{MaxRetries: 2},
+			statuses:     []map[string]int{{"2": 429, "3": 429}, {"2": 429}, {"2": 429}},
+			wantRequests: [][]string{{"1", "2", "3", "4", "5"}, {"2", "3"}, {"2"}},
+			wantStatus:   []int{200, 429, 200, 200, 200},
+		},
+		{
+			name:         "retry disabled",
+			retry:        BulkRetry{},
+			statuses:     []map[string]int{{"2": 429}},
+			wantRequests: [][]string{{"1", "2", "3", "4", "5"}},
+			wantStatus:   []int{200, 429, 200, 200, 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &bulkServer{statuses: tt.statuses}
+			client := newTestClient(t, srv.handle(t))
+
+			items, err := sendBulk(context.Background(), client, deleteChunks(t, 1, 2, 3, 4, 5), tt.retry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(srv.requests, tt.wantRequests) {
+				t.Errorf("requests = %v, want %v", srv.requests, tt.wantRequests)
+			}
+			for i, item := range items {
+				if item.ID != int64(i+1) || item.Status != tt.wantStatus[i] {
+					t.Errorf("item %d = ID %d status %d, want ID %d status %d", i, item.ID, item.Status, i+1, tt.wantStatus[i])
+				}
+				if failed := item.Error != nil; failed != (tt.wantStatus[i] >= 300) {
+					t.Errorf("item %d error = %v", i, item.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestSendBulkBackoffHonoursContext(t *testing.T) {
+	srv := &bulkServer{statuses: []map[string]int{{"1": 429}}}
+	client := newTestClient(t, srv.handle(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	retry := BulkRetry{MaxRetries: 1, Backoff: func(int) time.Duration { return time.Minute }}
+	if _, err := sendBulk(ctx, client, deleteChunks(t, 1), retry); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sendBulk() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBulkDeleteResultOrder(t *testing.T) {
+	srv := &bulkServer{statuses: []map[string]int{{"3": 429, "1": 429}, {"1": 429}}}
+	client := newTestClient(t, srv.handle(t))
+
+	result, err := NewRepository[testDoc](client, "cars", "car", nil).BulkDelete(context.Background(), []int64{3, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"3", "1", "2"}, {"3", "1"}, {"1"}}; !reflect.DeepEqual(srv.requests, want) {
+		t.Errorf("requests = %v, want %v", srv.requests, want)
+	}
+	if want := []int64{3, 1, 2}; !reflect.DeepEqual(result.Succeeded, want) || result.HasFailures() {
+		t.Errorf("succeeded = %v, failed = %v, want %v", result.Succeeded, result.Failed, want)
+	}
+}
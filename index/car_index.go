@@ -1,242 +1,146 @@
 package index
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-var carMapping = []byte(`
-{
-  "mappings": {
-    "properties": {
-      "id": { "type": "long" },
-      "user_id": { "type": "long" },
-      "user_name": { "type": "keyword" },
-      "stock_id": { "type": "long" },
-      "store_name": { "type": "keyword" },
-      "brand_id": { "type": "long" },
-      "brand_name": { "type": "keyword" },
-      "model_id": { "type": "long" },
-      "model_name": { "type": "keyword" },
-      "year": { "type": "long" },
-      "price": { "type": "long" },
-      "color": { "type": "keyword" },
-      "vin": { "type": "keyword" },
-      "description": { "type": "text" },
-      "city_id": { "type": "long" },
-      "city_name_tm": { "type": "keyword" },
-      "city_name_en": { "type": "keyword" },
-      "city_name_ru": { "type": "keyword" },
-      "name": { "type": "keyword" },
-      "mail": { "type": "keyword" },
-      "phone_number": { "type": "keyword" },
-      "is_comment": { "type": "boolean" },
-      "is_exchange": { "type": "boolean" },
-      "is_credit": { "type": "boolean" },
-      "images": { "type": "object", "enabled": true },
-      "status": { "type": "keyword" },
-      "created_at": { "type": "date" },
-      "updated_at": { "type": "date" },
-      "mileage": { "type": "long" },
-      "engine_capacity": { "type": "double" },
-      "engine_type": { "type": "keyword" },
-      "body_id": { "type": "long" },
-      "body_name_tm": { "type": "keyword" },
-      "body_name_en": { "type": "keyword" },
-      "body_name_ru": { "type": "keyword" },
-      "transmission": { "type": "keyword" },
-      "drive_type": { "type": "keyword" }
-    }
-  }
-}
-`)
+// carMapping is generated from the json and es tags of models.Car.
+var carMapping = mustMapping(models.Car{})
 
-func EnsureCarIndex(client *elasticsearch.Client, index string) error {
-	res, err := client.Indices.Exists([]string{index})
-	if err != nil {
-		return fmt.Errorf("Error checking if index exists: %v", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		fmt.Println("Using existing index:", index)
-		return nil
-	}
+// NewCarRepository returns a repository for car documents stored in index.
+func NewCarRepository(client *elasticsearch.Client, index string) *Repository[models.Car] {
+	return NewRepository[models.Car](client, index, "car", carMapping)
+}
 
-	res, err = client.Indices.Create(
-		index,
-		client.Indices.Create.WithBody(bytes.NewReader(carMapping)),
-	)
-	if err != nil {
-		return fmt.Errorf("Error creating index %s: %v", index, err)
-	}
-	defer res.Body.Close()
+func EnsureCarIndex(client *elasticsearch.Client, index string) error {
+	return EnsureCarIndexContext(context.Background(), client, index)
+}
 
-	if res.IsError() {
-		return fmt.Errorf("failed to create index %s: %s", index, res.String())
-	}
+// EnsureCarIndexContext creates the car index if it does not exist.
+func EnsureCarIndexContext(ctx context.Context, client *elasticsearch.Client, index string) error {
+	return NewCarRepository(client, index).Ensure(ctx)
+}
 
-	fmt.Println("Index created successfully:", index)
+// GetCar reads the car with the given ID. See Repository.Get.
+func GetCar(ctx context.Context, client *elasticsearch.Client, index string, carID int64) (*GetResult[models.Car], error) {
+	return NewCarRepository(client, index).Get(ctx, carID)
+}
 
-	return nil
+// MultiGetCars reads the cars with the given IDs in a single request.
+// See Repository.MultiGet.
+func MultiGetCars(ctx context.Context, client *elasticsearch.Client, index string, carIDs []int64) ([]GetResult[models.Car], error) {
+	return NewCarRepository(client, index).MultiGet(ctx, carIDs)
 }
 
-// IndexCar
 func IndexCar(client *elasticsearch.Client, index string, car *models.Car) error {
-	data, err := json.Marshal(car)
-	if err != nil {
-		return err
-	}
-
-	res, err := client.Index(
-		index,
-		bytes.NewReader(data),
-		client.Index.WithDocumentID(fmt.Sprintf("%d", car.ID)),
-		client.Index.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error indexing document ID=%d: %s", car.ID, res.String())
-	}
-	fmt.Printf("Document ID=%d indexed successfully\n", car.ID)
-
-	return nil
+	return IndexCarContext(context.Background(), client, index, car)
+}
+
+// IndexCarContext stores car, replacing any existing document with the same ID.
+func IndexCarContext(ctx context.Context, client *elasticsearch.Client, index string, car *models.Car) error {
+	return NewCarRepository(client, index).Index(ctx, car)
 }
 
 func UpdateCar(client *elasticsearch.Client, index string, car *models.Car) error {
-	data, err := json.Marshal(map[string]interface{}{
-		"doc":           car,
-		"doc_as_upsert": true,
-	})
-
-	if err != nil {
-		fmt.Println("Error marshalling update car:", err)
-	}
-
-	res, err := client.Update(
-		index,
-		fmt.Sprintf("%d", car.ID),
-		bytes.NewReader(data),
-		client.Update.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error updating document ID=%d: %s", car.ID, res.String())
-	}
-	fmt.Printf("Document ID=%d updated successfully\n", car.ID)
-	return nil
+	return UpdateCarContext(context.Background(), client, index, car)
 }
 
-func DeleteCar(client *elasticsearch.Client, index string, carID int64) error {
-	res, err := client.Delete(
-		index,
-		fmt.Sprintf("%d", carID),
-		client.Delete.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
+// UpdateCarContext merges car into the stored document, creating it if missing.
+func UpdateCarContext(ctx context.Context, client *elasticsearch.Client, index string, car *models.Car) error {
+	return NewCarRepository(client, index).Update(ctx, car)
+}
+
+// UpdateCarIf merges car into the stored document if it is still at
+// revision v. See Repository.UpdateIf.
+func UpdateCarIf(ctx context.Context, client *elasticsearch.Client, index string, car *models.Car, v Version) (*Version, error) {
+	return NewCarRepository(client, index).UpdateIf(ctx, car, v)
+}
+
+// IndexCarVersioned stores car unless the stored document has a newer
+// UpdatedAt. See Repository.IndexVersioned.
+func IndexCarVersioned(ctx context.Context, client *elasticsearch.Client, index string, car *models.Car) error {
+	return NewCarRepository(client, index).IndexVersioned(ctx, car)
+}
 
-	if res.IsError() {
-		return fmt.Errorf("error deleting document ID=%d: %s", carID, res.String())
-	}
+// BulkUpdateCarsIf partially updates the cars still at their revision in
+// versions. See Repository.BulkUpdateIf.
+func BulkUpdateCarsIf(ctx context.Context, client *elasticsearch.Client, index string, cars []models.Car, versions map[int64]Version) (*BulkResult, error) {
+	return NewCarRepository(client, index).BulkUpdateIf(ctx, cars, versions)
+}
 
-	fmt.Printf("Document ID=%d deleted successfully\n", carID)
+// BulkIndexCarsVersioned stores the cars not older than the stored
+// documents. See Repository.BulkIndexVersioned.
+func BulkIndexCarsVersioned(ctx context.Context, client *elasticsearch.Client, index string, cars []models.Car) (*BulkResult, error) {
+	return NewCarRepository(client, index).BulkIndexVersioned(ctx, cars)
+}
 
-	return nil
+// UpdateCarFields sets only the named fields of the stored car, e.g.
+// {"price": 15000, "status": "accepted"}. See Repository.UpdateFields.
+func UpdateCarFields(ctx context.Context, client *elasticsearch.Client, index string, carID int64, fields map[string]interface{}) error {
+	return NewCarRepository(client, index).UpdateFields(ctx, carID, fields)
 }
 
-func BulkUpdateCars(client *elasticsearch.Client, index string, cars []models.Car) error {
-	var buf bytes.Buffer
+// UpdateCarScript runs a painless script against the stored car.
+func UpdateCarScript(ctx context.Context, client *elasticsearch.Client, index string, carID int64, script Script) error {
+	return NewCarRepository(client, index).UpdateScript(ctx, carID, script)
+}
 
-	for _, car := range cars {
-		meta := []byte(fmt.Sprintf(`{ "update": { "_index": "%s", "_id": "%d" } }%s`, index, car.ID, "\n"))
-		doc, err := json.Marshal(map[string]interface{}{"doc": car})
-		if err != nil {
-			return err
-		}
-		doc = append(doc, "\n"...)
-		buf.Write(meta)
-		buf.Write(doc)
-	}
+// IncrementCarField adds delta to a counter of the stored car, such as
+// view_count or favorites.
+func IncrementCarField(ctx context.Context, client *elasticsearch.Client, index string, carID int64, field string, delta int64) error {
+	return NewCarRepository(client, index).Increment(ctx, carID, field, delta)
+}
 
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
+func DeleteCar(client *elasticsearch.Client, index string, carID int64) error {
+	return DeleteCarContext(context.Background(), client, index, carID)
+}
 
-	if res.IsError() {
-		return fmt.Errorf("bulk update error: %s", res.String())
-	}
+// DeleteCarContext removes the car with the given ID.
+func DeleteCarContext(ctx context.Context, client *elasticsearch.Client, index string, carID int64) error {
+	return NewCarRepository(client, index).Delete(ctx, carID)
+}
 
-	fmt.Println("Bulk update successful")
-	return nil
+func BulkUpdateCars(client *elasticsearch.Client, index string, cars []models.Car) (*BulkResult, error) {
+	return BulkUpdateCarsContext(context.Background(), client, index, cars)
 }
 
-func BulkDeleteCars(client *elasticsearch.Client, index string, carIDs []int64) error {
-	var buf bytes.Buffer
+// BulkUpdateCarsContext partially updates cars in a single bulk request.
+func BulkUpdateCarsContext(ctx context.Context, client *elasticsearch.Client, index string, cars []models.Car) (*BulkResult, error) {
+	return NewCarRepository(client, index).BulkUpdate(ctx, cars)
+}
 
-	for _, id := range carIDs {
-		meta := []byte(fmt.Sprintf(`{ "delete": { "_index": "%s", "_id": "%d" } }%s`, index, id, "\n"))
-		buf.Write(meta)
-	}
+func BulkDeleteCars(client *elasticsearch.Client, index string, carIDs []int64) (*BulkResult, error) {
+	return BulkDeleteCarsContext(context.Background(), client, index, carIDs)
+}
 
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
+// BulkDeleteCarsContext removes the cars with the given IDs in a single bulk request.
+func BulkDeleteCarsContext(ctx context.Context, client *elasticsearch.Client, index string, carIDs []int64) (*BulkResult, error) {
+	return NewCarRepository(client, index).BulkDelete(ctx, carIDs)
+}
 
-	if res.IsError() {
-		return fmt.Errorf("bulk delete error: %s", res.String())
-	}
+func DeleteCarsByUserID(client *elasticsearch.Client, index string, userID int64) error {
+	return DeleteCarsByUserIDContext(context.Background(), client, index, userID)
+}
 
-	fmt.Println("Bulk delete successful")
-	return nil
+// DeleteCarsByUserIDContext removes every car owned by userID.
+func DeleteCarsByUserIDContext(ctx context.Context, client *elasticsearch.Client, index string, userID int64) error {
+	return NewCarRepository(client, index).DeleteByUserID(ctx, userID)
 }
 
 func DeleteFeedsByUserID(client *elasticsearch.Client, index string, userID int64) error {
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"term": map[string]interface{}{
-				"user_id": userID,
-			},
-		},
-	}
-
-	data, err := json.Marshal(query)
-	if err != nil {
-		return fmt.Errorf("Error marshalling query: %w", err)
-	}
-
-	res, err := client.DeleteByQuery(
-		[]string{index},
-		bytes.NewReader(data),
-		client.DeleteByQuery.WithRefresh(true),
-	)
-	if err != nil {
-		return fmt.Errorf("error deleting by user_id=%d: %v", userID, err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("delete by query failed for user_id=%d: %s", userID, res.String())
-	}
-
-	fmt.Printf("All documents with user_id=%d deleted successfully from index=%s\n", userID, index)
-	return nil
+	return DeleteFeedsByUserIDContext(context.Background(), client, index, userID)
+}
+
+// DeleteFeedsByUserIDContext removes every document owned by userID from index.
+func DeleteFeedsByUserIDContext(ctx context.Context, client *elasticsearch.Client, index string, userID int64) error {
+	return NewCarRepository(client, index).DeleteByUserID(ctx, userID)
+}
+
+// MigrateCarIndex moves the car alias to a new versioned index created with
+// the current car mapping. See Repository.Migrate.
+func MigrateCarIndex(ctx context.Context, client *elasticsearch.Client, alias string, opts MigrateOptions) (*MigrateResult, error) {
+	return NewCarRepository(client, alias).Migrate(ctx, opts)
 }
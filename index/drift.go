@@ -0,0 +1,309 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+)
+
+// DriftKind classifies a difference found by the mapping checks.
+type DriftKind string
+
+const (
+	DriftMissing      DriftKind = "missing"       // field expected but absent
+	DriftExtra        DriftKind = "extra"         // field present but not expected
+	DriftTypeMismatch DriftKind = "type_mismatch" // field present with another type
+)
+
+// FieldDrift is a single difference between an expected and an actual field set.
+type FieldDrift struct {
+	Field   string
+	Kind    DriftKind
+	Compare string // "model/embedded" or "embedded/live"
+	Want    string // expected type, if any
+	Got     string // actual type, if any
+}
+
+func (d FieldDrift) String() string {
+	switch d.Kind {
+	case DriftMissing:
+		return fmt.Sprintf("%s: %s missing (want %s)", d.Compare, d.Field, d.Want)
+	case DriftExtra:
+		return fmt.Sprintf("%s: %s extra (got %s)", d.Compare, d.Field, d.Got)
+	default:
+		return fmt.Sprintf("%s: %s type %s, want %s", d.Compare, d.Field, d.Got, d.Want)
+	}
+}
+
+// DriftReport lists every difference found by a mapping check.
+type DriftReport struct {
+	Drifts []FieldDrift
+}
+
+// HasDrift reports whether any difference was found.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+func (r *DriftReport) String() string {
+	lines := make([]string, len(r.Drifts))
+	for i, d := range r.Drifts {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckModelMapping compares the json tags of T with the embedded mapping.
+// It does not contact Elasticsearch, so it can run in unit tests with a
+// repository built on a nil client.
+func (r *Repository[T]) CheckModelMapping() (*DriftReport, error) {
+	var zero T
+	return CompareModelMapping(zero, r.mapping)
+}
+
+// CheckMapping compares the json tags of T with the embedded mapping and the
+// embedded mapping with the live mapping of the repository index.
+func (r *Repository[T]) CheckMapping(ctx context.Context) (*DriftReport, error) {
+	report, err := r.CheckModelMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.client.Indices.GetMapping(
+		r.client.Indices.GetMapping.WithContext(ctx),
+		r.client.Indices.GetMapping.WithIndex(r.index),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting mapping of %s: %w", r.index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("failed to get mapping of %s: %w", r.index, eserrors.FromResponse(res))
+	}
+
+	// The response is keyed by concrete index name, which differs from
+	// r.index when it is an alias.
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, fmt.Errorf("error parsing mapping response: %w", err)
+	}
+	if len(indices) != 1 {
+		return nil, fmt.Errorf("mapping of %s spans %d indices, expected one", r.index, len(indices))
+	}
+	for _, live := range indices {
+		drift, err := CompareMappings(r.mapping, live)
+		if err != nil {
+			return nil, err
+		}
+		report.Drifts = append(report.Drifts, drift.Drifts...)
+	}
+	return report, nil
+}
+
+// CompareModelMapping compares the json-tagged fields of model, a struct
+// value or pointer, with the properties of mapping, an index create body.
+// Fields tagged es:"-" are not expected in the mapping; a type or subfield
+// declared in the es tag must be mapped as declared.
+func CompareModelMapping(model interface{}, mapping []byte) (*DriftReport, error) {
+	fields, err := mappingFields(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+
+	report := &DriftReport{}
+	seen := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonFieldName(f)
+		tag := f.Tag.Get("es")
+		if name == "" || tag == "-" {
+			continue
+		}
+		seen[name] = true
+
+		// A type declared in the es tag must match exactly; without one any
+		// mapping type that can hold the Go type is accepted.
+		want := goTypeCategory(f.Type)
+		declared := false
+		var sub map[string]interface{}
+		if tag != "" {
+			prop, err := fieldMapping(f.Type, tag)
+			if err != nil {
+				return nil, fmt.Errorf("field %s.%s: %w", t.Name(), f.Name, err)
+			}
+			if typ := strings.TrimSpace(strings.Split(tag, ",")[0]); typ != "" {
+				want, declared = typ, true
+			}
+			sub, _ = prop["fields"].(map[string]interface{})
+		}
+
+		got, ok := fields[name]
+		switch {
+		case !ok:
+			report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftMissing, Compare: "model/embedded", Want: want})
+			continue
+		case declared && got != want,
+			!declared && want != "" && !typeCompatible(want, got):
+			report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftTypeMismatch, Compare: "model/embedded", Want: want, Got: got})
+		}
+		for _, subName := range sortedKeys(sub) {
+			full := name + "." + subName
+			if _, ok := fields[full]; !ok {
+				report.Drifts = append(report.Drifts, FieldDrift{Field: full, Kind: DriftMissing, Compare: "model/embedded", Want: "text"})
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(fields) {
+		// Subfields and object properties belong to a top-level field.
+		if strings.Contains(name, ".") || seen[name] {
+			continue
+		}
+		report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftExtra, Compare: "model/embedded", Got: fields[name]})
+	}
+	return report, nil
+}
+
+// CompareMappings compares the fields of want, an index create body, with
+// got, a create body or the per-index entry of a get mapping response.
+func CompareMappings(want, got []byte) (*DriftReport, error) {
+	wantFields, err := mappingFields(want)
+	if err != nil {
+		return nil, err
+	}
+	gotFields, err := mappingFields(got)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{}
+	for _, name := range sortedKeys(wantFields) {
+		g, ok := gotFields[name]
+		switch {
+		case !ok:
+			report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftMissing, Compare: "embedded/live", Want: wantFields[name]})
+		case g != wantFields[name]:
+			report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftTypeMismatch, Compare: "embedded/live", Want: wantFields[name], Got: g})
+		}
+	}
+	for _, name := range sortedKeys(gotFields) {
+		if _, ok := wantFields[name]; !ok {
+			report.Drifts = append(report.Drifts, FieldDrift{Field: name, Kind: DriftExtra, Compare: "embedded/live", Got: gotFields[name]})
+		}
+	}
+	return report, nil
+}
+
+type mappingProperty struct {
+	Type       string                     `json:"type"`
+	Properties map[string]mappingProperty `json:"properties"`
+	Fields     map[string]mappingProperty `json:"fields"`
+}
+
+// mappingFields flattens the properties of a mapping into field name to
+// type, with object properties and multi-fields as dotted names.
+func mappingFields(mapping []byte) (map[string]string, error) {
+	var body struct {
+		Mappings struct {
+			Properties map[string]mappingProperty `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal(mapping, &body); err != nil {
+		return nil, fmt.Errorf("error parsing mapping: %w", err)
+	}
+
+	fields := map[string]string{}
+	var walk func(prefix string, props map[string]mappingProperty)
+	walk = func(prefix string, props map[string]mappingProperty) {
+		for name, p := range props {
+			full := prefix + name
+			typ := p.Type
+			if typ == "" {
+				typ = "object"
+			}
+			fields[full] = typ
+			walk(full+".", p.Properties)
+			walk(full+".", p.Fields)
+		}
+	}
+	walk("", body.Mappings.Properties)
+	return fields, nil
+}
+
+// goTypeCategory returns the mapping type category of a Go field type, or
+// "" if any mapping type is acceptable.
+func goTypeCategory(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return ""
+}
+
+// typeCompatible reports whether a mapping type can hold a Go type category.
+func typeCompatible(category, mappingType string) bool {
+	switch category {
+	case "integer":
+		return mappingType == "long" || mappingType == "integer" || mappingType == "short" || mappingType == "byte" || mappingType == "unsigned_long"
+	case "float":
+		return mappingType == "double" || mappingType == "float" || mappingType == "half_float" || mappingType == "scaled_float"
+	case "string":
+		return mappingType == "keyword" || mappingType == "text" || mappingType == "wildcard" || mappingType == "constant_keyword"
+	case "object":
+		return mappingType == "object" || mappingType == "nested" || mappingType == "flattened"
+	}
+	return category == mappingType
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+package index
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCompareMappings(t *testing.T) {
+	want := []byte(`{"settings":{"number_of_shards":1},"mappings":{"properties":{
+		"id":{"type":"long"},
+		"price":{"type":"long"},
+		"name":{"type":"keyword","fields":{"text":{"type":"text","analyzer":"english"}}},
+		"location":{"properties":{"city_id":{"type":"long"}}}
+	}}}`)
+
+	tests := []struct {
+		name string
+		got  string
+		want []FieldDrift
+	}{
+		{
+			name: "same fields",
+			got: `{"mappings":{"dynamic":"strict","properties":{
+				"id":{"type":"long"},
+				"price":{"type":"long"},
+				"name":{"type":"keyword","fields":{"text":{"type":"text"}}},
+				"location":{"properties":{"city_id":{"type":"long"}}}
+			}}}`,
+		},
+		{
+			name: "missing, extra and mismatched fields",
+			got: `{"mappings":{"properties":{
+				"id":{"type":"long"},
+				"price":{"type":"float"},
+				"name":{"type":"keyword"},
+				"location":{"properties":{"city_id":{"type":"long"},"region_id":{"type":"long"}}},
+				"color":{"type":"keyword"}
+			}}}`,
+			want: []FieldDrift{
+				{Field: "name.text", Kind: DriftMissing, Compare: "embedded/live", Want: "text"},
+				{Field: "price", Kind: DriftTypeMismatch, Compare: "embedded/live", Want: "long", Got: "float"},
+				{Field: "color", Kind: DriftExtra, Compare: "embedded/live", Got: "keyword"},
+				{Field: "location.region_id", Kind: DriftExtra, Compare: "embedded/live", Got: "long"},
+			},
+		},
+		{
+			name: "subfield and object mismatches",
+			got: `{"mappings":{"properties":{
+				"id":{"type":"long"},
+				"price":{"type":"long"},
+				"name":{"type":"text","fields":{"text":{"type":"keyword"}}},
+				"location":{"type":"keyword"}
+			}}}`,
+			want: []FieldDrift{
+				{Field: "location", Kind: DriftTypeMismatch, Compare: "embedded/live", Want: "object", Got: "keyword"},
+				{Field: "location.city_id", Kind: DriftMissing, Compare: "embedded/live", Want: "long"},
+				{Field: "name", Kind: DriftTypeMismatch, Compare: "embedded/live", Want: "keyword", Got: "text"},
+				{Field: "name.text", Kind: DriftTypeMismatch, Compare: "embedded/live", Want: "text", Got: "keyword"},
+			},
+		},
+		{
+			name: "empty live mapping",
+			got:  `{"mappings":{}}`,
+			want: []FieldDrift{
+				{Field: "id", Kind: DriftMissing, Compare: "embedded/live", Want: "long"},
+				{Field: "location", Kind: DriftMissing, Compare: "embedded/live", Want: "object"},
+				{Field: "location.city_id", Kind: DriftMissing, Compare: "embedded/live", Want: "long"},
+				{Field: "name", Kind: DriftMissing, Compare: "embedded/live", Want: "keyword"},
+				{Field: "name.text", Kind: DriftMissing, Compare: "embedded/live", Want: "text"},
+				{Field: "price", Kind: DriftMissing, Compare: "embedded/live", Want: "long"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := CompareMappings(want, []byte(tt.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.HasDrift() != (len(tt.want) > 0) {
+				t.Errorf("HasDrift() = %v, want %v", report.HasDrift(), len(tt.want) > 0)
+			}
+			if !reflect.DeepEqual(report.Drifts, tt.want) {
+				t.Errorf("drifts:\n%s\nwant:\n%s", report, &DriftReport{Drifts: tt.want})
+			}
+		})
+	}
+
+	if _, err := CompareMappings(want, []byte(`{"mappings":`)); err == nil {
+		t.Error("CompareMappings() with invalid JSON succeeded")
+	}
+}
+
+func TestCompareModelMappingTags(t *testing.T) {
+	type doc struct {
+		ID       int64  `json:"id"`
+		Name     string `json:"name" es:"keyword,sub=text:english"`
+		Body     string `json:"body" es:"text"`
+		Internal string `json:"internal" es:"-"`
+	}
+	mapping := []byte(`{"mappings":{"properties":{
+		"id":{"type":"long"},
+		"name":{"type":"keyword"},
+		"body":{"type":"keyword"}
+	}}}`)
+
+	report, err := CompareModelMapping(doc{}, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldDrift{
+		{Field: "name.text", Kind: DriftMissing, Compare: "model/embedded", Want: "text"},
+		{Field: "body", Kind: DriftTypeMismatch, Compare: "model/embedded", Want: "text", Got: "keyword"},
+	}
+	if len(report.Drifts) != len(want) {
+		t.Fatalf("drifts:\n%s\nwant %v", report, want)
+	}
+	for _, w := range want {
+		found := false
+		for _, d := range report.Drifts {
+			found = found || d == w
+		}
+		if !found {
+			t.Errorf("missing drift %v in:\n%s", w, report)
+		}
+	}
+}
+
+// testDoc is a minimal Document for repository tests.
+type testDoc struct {
+	ID    int64  `json:"id" es:"long"`
+	Color string `json:"color" es:"keyword"`
+}
+
+func (d testDoc) DocumentID() int64 { return d.ID }
+
+func TestCheckMappingLive(t *testing.T) {
+
+	var path string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		// Keyed by the concrete index behind the alias.
+		io.WriteString(w, `{"docs_v2":{"mappings":{"properties":{"id":{"type":"long"},"color":{"type":"text"}}}}}`)
+	})
+	repo := NewRepository[testDoc](client, "docs", "doc", mustMapping(testDoc{}))
+
+	report, err := repo.CheckMapping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/docs/_mapping" {
+		t.Errorf("request path = %s, want /docs/_mapping", path)
+	}
+	want := []FieldDrift{
+		{Field: "color", Kind: DriftTypeMismatch, Compare: "embedded/live", Want: "keyword", Got: "text"},
+	}
+	if !reflect.DeepEqual(report.Drifts, want) {
+		t.Errorf("drifts:\n%s\nwant:\n%s", report, &DriftReport{Drifts: want})
+	}
+}
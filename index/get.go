@@ -0,0 +1,137 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GetResult is a document read by ID. Version can be passed to UpdateIf
+// and BulkUpdateIf to update the document only if it has not changed since.
+type GetResult[T any] struct {
+	ID      int64
+	Found   bool
+	Doc     *T // nil if not found
+	Version Version
+}
+
+// getDoc is a document of a get or multi get response.
+type getDoc[T any] struct {
+	ID          string          `json:"_id"`
+	Found       bool            `json:"found"`
+	SeqNo       int64           `json:"_seq_no"`
+	PrimaryTerm int64           `json:"_primary_term"`
+	Source      *T              `json:"_source"`
+	Error       *eserrors.Cause `json:"error"`
+}
+
+func (d *getDoc[T]) result(id int64) GetResult[T] {
+	result := GetResult[T]{ID: id, Found: d.Found}
+	if d.Found {
+		result.Doc = d.Source
+		result.Version = Version{SeqNo: d.SeqNo, PrimaryTerm: d.PrimaryTerm}
+	}
+	return result
+}
+
+// Get reads the document with the given ID. A missing document is not an
+// error; the result has Found false.
+func (r *Repository[T]) Get(ctx context.Context, id int64) (_ *GetResult[T], err error) {
+	ctx, op := startOperation(ctx, "get", r.kind, r.index, attribute.Int64("elasticsearch.doc_id", id))
+	defer func() { op.end(err) }()
+
+	res, err := r.client.Get(
+		r.index,
+		strconv.FormatInt(id, 10),
+		r.client.Get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading get response: %w", err)
+	}
+	// A missing document is a 404 with found false, a missing index a 404
+	// with an error.
+	if res.IsError() {
+		if e := eserrors.Parse(res.StatusCode, data); res.StatusCode != http.StatusNotFound || e.Type != "" {
+			return nil, fmt.Errorf("error getting %s ID=%d: %w", r.kind, id, e)
+		}
+	}
+	var doc getDoc[T]
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing get response: %w", err)
+	}
+
+	result := doc.result(id)
+	r.logger.DebugContext(ctx, "got document", "kind", r.kind, "index", r.index, "doc_id", id, "found", result.Found, "took", op.took())
+	return &result, nil
+}
+
+// MultiGet reads the documents with the given IDs in a single request. The
+// results are in the order of ids, with Found false for missing documents.
+func (r *Repository[T]) MultiGet(ctx context.Context, ids []int64) (_ []GetResult[T], err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	ctx, op := startOperation(ctx, "multi_get", r.kind, r.index, attribute.Int("elasticsearch.doc_count", len(ids)))
+	defer func() { op.end(err) }()
+
+	docIDs := make([]string, len(ids))
+	for i, id := range ids {
+		docIDs[i] = strconv.FormatInt(id, 10)
+	}
+	data, err := json.Marshal(map[string]interface{}{"ids": docIDs})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling ids: %w", err)
+	}
+
+	res, err := r.client.Mget(
+		bytes.NewReader(data),
+		r.client.Mget.WithContext(ctx),
+		r.client.Mget.WithIndex(r.index),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting %s documents: %w", r.kind, eserrors.FromResponse(res))
+	}
+
+	var body struct {
+		Docs []getDoc[T] `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error parsing multi get response: %w", err)
+	}
+	if len(body.Docs) != len(ids) {
+		return nil, fmt.Errorf("multi get response has %d docs, expected %d", len(body.Docs), len(ids))
+	}
+
+	results := make([]GetResult[T], len(ids))
+	found := 0
+	for i, doc := range body.Docs {
+		if doc.Error != nil {
+			return nil, fmt.Errorf("error getting %s ID=%d: %w", r.kind, ids[i], eserrors.New(0, doc.Error.Type, doc.Error.Reason))
+		}
+		results[i] = doc.result(ids[i])
+		if results[i].Found {
+			found++
+		}
+	}
+
+	r.logger.DebugContext(ctx, "got documents", "kind", r.kind, "index", r.index, "count", len(ids), "found", found, "took", op.took())
+	return results, nil
+}
@@ -0,0 +1,7 @@
+package index
+
+import "log/slog"
+
+// discardLogger is the logger of repositories and bulk indexers that were
+// not given one.
+var discardLogger = slog.New(slog.DiscardHandler)
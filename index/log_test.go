@@ -0,0 +1,39 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryWithLogger(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"_id":"1","found":true,"_seq_no":1,"_primary_term":1,"_source":{"id":1,"color":"red"}}`)
+	})
+
+	var cars, trucks bytes.Buffer
+	base := NewRepository[testDoc](client, "cars", "car", nil)
+	carRepo := base.WithLogger(slog.New(slog.NewTextHandler(&cars, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	truckRepo := NewRepository[testDoc](client, "trucks", "truck", nil).
+		WithLogger(slog.New(slog.NewTextHandler(&trucks, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	for _, repo := range []*Repository[testDoc]{base, carRepo, truckRepo} {
+		if _, err := repo.Get(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := cars.String(); strings.Count(got, "got document") != 1 || !strings.Contains(got, "index=cars") {
+		t.Errorf("car log = %q, want one debug record of index cars", got)
+	}
+	if got := trucks.String(); got != "" {
+		t.Errorf("truck log = %q, want nothing below info level", got)
+	}
+	if base.logger != discardLogger {
+		t.Error("WithLogger changed the logger of the original repository")
+	}
+}
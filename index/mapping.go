@@ -0,0 +1,138 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// indexSettings are the settings shared by every vehicle index. Analyzers
+// referenced from es struct tags must be defined here or built in.
+var indexSettings = map[string]interface{}{
+	"analysis": map[string]interface{}{
+		"analyzer": map[string]interface{}{
+			"turkmen_folding": map[string]interface{}{
+				"type":      "custom",
+				"tokenizer": "standard",
+				"filter":    []string{"lowercase", "asciifolding"},
+			},
+		},
+	},
+}
+
+// Mapping returns the index create body for model, a struct value or
+// pointer, with a property for every json-tagged field.
+//
+// The type of a property comes from the field's es tag, or from its Go type
+// when there is none: integers map to long, floats to double, strings to
+// keyword, bool to boolean, time.Time to date and anything else to object.
+// Pointers and slices map like their element type. The tag accepts options
+// after the type:
+//
+//	es:"keyword,sub=text:turkmen_folding"        // keyword with a text subfield
+//	es:"text,analyzer=russian"                   // analysed text
+//	es:"text,analyzer=turkmen_folding,sub=ru:russian"
+//	es:"-"                                       // not mapped
+//
+// An empty type, as in es:",sub=text:english", keeps the default.
+func Mapping(model interface{}) ([]byte, error) {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonFieldName(f)
+		tag := f.Tag.Get("es")
+		if name == "" || tag == "-" {
+			continue
+		}
+
+		prop, err := fieldMapping(f.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t.Name(), f.Name, err)
+		}
+		properties[name] = prop
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"settings": indexSettings,
+		"mappings": map[string]interface{}{"properties": properties},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling %s mapping: %w", t.Name(), err)
+	}
+	return data, nil
+}
+
+// mustMapping is Mapping for the package level mapping variables.
+func mustMapping(model interface{}) []byte {
+	data, err := Mapping(model)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func fieldMapping(t reflect.Type, tag string) (map[string]interface{}, error) {
+	opts := strings.Split(tag, ",")
+
+	typ := strings.TrimSpace(opts[0])
+	if typ == "" {
+		typ = defaultMappingType(t)
+	}
+	prop := map[string]interface{}{"type": typ}
+
+	for _, opt := range opts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(opt), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid es tag option %q", opt)
+		}
+		switch key {
+		case "analyzer":
+			prop["analyzer"] = value
+		case "sub":
+			sub, analyzer, ok := strings.Cut(value, ":")
+			if !ok || sub == "" || analyzer == "" {
+				return nil, fmt.Errorf("invalid es tag subfield %q, want name:analyzer", value)
+			}
+			fields, _ := prop["fields"].(map[string]interface{})
+			if fields == nil {
+				fields = map[string]interface{}{}
+				prop["fields"] = fields
+			}
+			fields[sub] = map[string]interface{}{"type": "text", "analyzer": analyzer}
+		default:
+			return nil, fmt.Errorf("unknown es tag option %q", key)
+		}
+	}
+	return prop, nil
+}
+
+func defaultMappingType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "long"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "keyword"
+	case reflect.Bool:
+		return "boolean"
+	}
+	return "object"
+}
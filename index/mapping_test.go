@@ -0,0 +1,107 @@
+package index
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		tag     string
+		want    string
+		wantErr string
+	}{
+		{"int default", reflect.TypeOf(int64(0)), "", `{"type":"long"}`, ""},
+		{"float default", reflect.TypeOf(0.0), "", `{"type":"double"}`, ""},
+		{"string default", reflect.TypeOf(""), "", `{"type":"keyword"}`, ""},
+		{"bool default", reflect.TypeOf(false), "", `{"type":"boolean"}`, ""},
+		{"time default", reflect.TypeOf(time.Time{}), "", `{"type":"date"}`, ""},
+		{"pointer and slice use element", reflect.TypeOf([]*int64{}), "", `{"type":"long"}`, ""},
+		{"other is object", reflect.TypeOf(map[string]int{}), "", `{"type":"object"}`, ""},
+		{"explicit type", reflect.TypeOf(""), "text", `{"type":"text"}`, ""},
+		{"analyzer", reflect.TypeOf(""), "text,analyzer=russian", `{"type":"text","analyzer":"russian"}`, ""},
+		{"subfield", reflect.TypeOf(""), "keyword,sub=text:turkmen_folding",
+			`{"type":"keyword","fields":{"text":{"type":"text","analyzer":"turkmen_folding"}}}`, ""},
+		{"several subfields and default type", reflect.TypeOf(""), ",sub=text:english, sub=ru:russian",
+			`{"type":"keyword","fields":{"text":{"type":"text","analyzer":"english"},"ru":{"type":"text","analyzer":"russian"}}}`, ""},
+		{"sub without colon", reflect.TypeOf(""), "keyword,sub=text", "", "invalid es tag subfield"},
+		{"sub without analyzer", reflect.TypeOf(""), "keyword,sub=text:", "", "invalid es tag subfield"},
+		{"option without value", reflect.TypeOf(""), "text,analyzer", "", "invalid es tag option"},
+		{"unknown option", reflect.TypeOf(""), "text,boost=2", "", "unknown es tag option"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fieldMapping(tt.typ, tt.tag)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("fieldMapping() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			var gotMap map[string]interface{}
+			json.Unmarshal(gotJSON, &gotMap)
+			if !reflect.DeepEqual(gotMap, want) {
+				t.Errorf("fieldMapping() = %s, want %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapping(t *testing.T) {
+	type doc struct {
+		ID       int64   `json:"id"`
+		Name     *string `json:"name" es:"keyword,sub=text:english"`
+		Internal string  `json:"internal" es:"-"`
+		Skipped  string  `json:"-"`
+		private  string
+	}
+
+	data, err := Mapping(&doc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := mappingFields(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"id": "long", "name": "keyword", "name.text": "text"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Mapping() fields = %v, want %v", fields, want)
+	}
+}
+
+func TestMappingErrors(t *testing.T) {
+	type badTag struct {
+		Name string `json:"name" es:"keyword,sub=text"`
+	}
+	tests := []struct {
+		name  string
+		model interface{}
+		want  string
+	}{
+		{"not a struct", 42, "model must be a struct"},
+		{"nil", nil, "model must be a struct"},
+		{"bad tag", badTag{}, "field badTag.Name: invalid es tag subfield"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Mapping(tt.model)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Mapping() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MigrateOptions controls a Repository.Migrate run.
+type MigrateOptions struct {
+	// DeleteOld deletes the previous index once the alias has been swapped.
+	DeleteOld bool
+	// SkipCountCheck swaps the alias even if the document counts of the old
+	// and new index differ. Migrate does not carry over writes made to the
+	// old index after the reindex started, so writes must be paused for the
+	// whole migration; this option does not make it safe to keep writing.
+	SkipCountCheck bool
+}
+
+// ReindexPollInterval is how often Migrate checks the progress of the
+// reindex task.
+var ReindexPollInterval = 5 * time.Second
+
+// MigrateResult describes a completed migration.
+type MigrateResult struct {
+	OldIndex string // empty if the alias did not exist yet
+	NewIndex string
+	Version  int
+	DocCount int64
+}
+
+var versionSuffix = regexp.MustCompile(`_v(\d+)$`)
+
+// Migrate moves the repository index, used as an alias, to a new versioned
+// index created with the current mapping: it creates {alias}_v{N}, reindexes
+// the documents from the current alias target, checks that the document
+// counts match and atomically points the alias at the new index.
+//
+// A concrete index that is still named like the alias, as created by Ensure,
+// is reindexed into {alias}_v1 and replaced by the alias in the same atomic
+// step, so it is always deleted.
+//
+// Writes to the alias must be paused while Migrate runs: documents written
+// to the old index after the reindex started are lost when the alias is
+// swapped. If Migrate fails before the swap, the new index is deleted and
+// the alias is left unchanged, so it can simply be run again.
+func (r *Repository[T]) Migrate(ctx context.Context, opts MigrateOptions) (_ *MigrateResult, err error) {
+	ctx, op := startOperation(ctx, "migrate", r.kind, r.index)
+	defer func() { op.end(err) }()
+
+	alias := r.index
+
+	targets, err := r.aliasTargets(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) > 1 {
+		return nil, fmt.Errorf("alias %s points to %d indices, expected one", alias, len(targets))
+	}
+
+	result := &MigrateResult{Version: 1}
+	legacy := false
+	if len(targets) == 1 {
+		result.OldIndex = targets[0]
+		if m := versionSuffix.FindStringSubmatch(result.OldIndex); m != nil {
+			v, _ := strconv.Atoi(m[1])
+			result.Version = v + 1
+		}
+	} else {
+		res, err := r.client.Indices.Exists([]string{alias}, r.client.Indices.Exists.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("error checking if %s index exists: %w", r.kind, err)
+		}
+		res.Body.Close()
+		if res.StatusCode == 200 {
+			result.OldIndex = alias
+			legacy = true
+		}
+	}
+	result.NewIndex = fmt.Sprintf("%s_v%d", alias, result.Version)
+
+	res, err := r.client.Indices.Create(
+		result.NewIndex,
+		r.client.Indices.Create.WithContext(ctx),
+		r.client.Indices.Create.WithBody(bytes.NewReader(r.mapping)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s index %s: %w", r.kind, result.NewIndex, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("failed to create %s index %s: %w", r.kind, result.NewIndex, eserrors.FromResponse(res))
+	}
+	r.logger.InfoContext(ctx, "created index", "kind", r.kind, "index", result.NewIndex, "status", res.StatusCode)
+
+	// Until the alias is swapped, a failure leaves the new index unused;
+	// delete it so the next run can create it again.
+	swapped := false
+	defer func() {
+		if err == nil || swapped {
+			return
+		}
+		if derr := r.deleteIndex(context.WithoutCancel(ctx), result.NewIndex); derr != nil {
+			r.logger.WarnContext(ctx, "failed to delete index of failed migration", "kind", r.kind, "index", result.NewIndex, "error", derr)
+			return
+		}
+		r.logger.InfoContext(ctx, "deleted index of failed migration", "kind", r.kind, "index", result.NewIndex)
+	}()
+
+	if result.OldIndex != "" {
+		if err := r.reindex(ctx, result.OldIndex, result.NewIndex); err != nil {
+			return nil, err
+		}
+
+		oldCount, err := r.count(ctx, result.OldIndex)
+		if err != nil {
+			return nil, err
+		}
+		if result.DocCount, err = r.count(ctx, result.NewIndex); err != nil {
+			return nil, err
+		}
+		if oldCount != result.DocCount && !opts.SkipCountCheck {
+			return nil, fmt.Errorf("document count mismatch after reindex: %s has %d, %s has %d; alias %s left unchanged",
+				result.OldIndex, oldCount, result.NewIndex, result.DocCount, alias)
+		}
+	}
+
+	actions := []map[string]interface{}{}
+	switch {
+	case legacy:
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": result.OldIndex}})
+	case result.OldIndex != "":
+		actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": result.OldIndex, "alias": alias}})
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]interface{}{"index": result.NewIndex, "alias": alias}})
+
+	if err := r.updateAliases(ctx, actions); err != nil {
+		return nil, err
+	}
+	swapped = true
+	op.span.SetAttributes(attribute.String("elasticsearch.new_index", result.NewIndex), attribute.Int64("elasticsearch.doc_count", result.DocCount))
+	r.logger.InfoContext(ctx, "swapped alias", "kind", r.kind, "alias", alias, "index", result.NewIndex, "old_index", result.OldIndex, "doc_count", result.DocCount)
+
+	if opts.DeleteOld && result.OldIndex != "" && !legacy {
+		if err := r.deleteIndex(ctx, result.OldIndex); err != nil {
+			return result, err
+		}
+		r.logger.InfoContext(ctx, "deleted old index", "kind", r.kind, "index", result.OldIndex)
+	}
+
+	return result, nil
+}
+
+// aliasTargets returns the indices alias points to, or none if it does not exist.
+func (r *Repository[T]) aliasTargets(ctx context.Context, alias string) ([]string, error) {
+	res, err := r.client.Indices.GetAlias(
+		r.client.Indices.GetAlias.WithContext(ctx),
+		r.client.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting alias %s: %w", alias, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("failed to get alias %s: %w", alias, eserrors.FromResponse(res))
+	}
+
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, fmt.Errorf("error parsing alias response: %w", err)
+	}
+
+	targets := make([]string, 0, len(indices))
+	for index := range indices {
+		targets = append(targets, index)
+	}
+	return targets, nil
+}
+
+// reindex copies source into dest with a reindex task and waits for it.
+// The start request returns at once, so large indices are not bound by the
+// client timeout and the request is not re-sent by the client retries after
+// a timeout, which would start a second copy. If waiting fails or ctx is
+// done first the task is cancelled.
+func (r *Repository[T]) reindex(ctx context.Context, source, dest string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling reindex request: %w", err)
+	}
+
+	res, err := r.client.Reindex(
+		bytes.NewReader(data),
+		r.client.Reindex.WithContext(ctx),
+		r.client.Reindex.WithWaitForCompletion(false),
+		r.client.Reindex.WithRefresh(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error reindexing %s into %s: %w", source, dest, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("reindex of %s into %s failed: %w", source, dest, eserrors.FromResponse(res))
+	}
+
+	var started struct {
+		Task string `json:"task"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&started); err != nil {
+		return fmt.Errorf("error parsing reindex response: %w", err)
+	}
+	if started.Task == "" {
+		return fmt.Errorf("reindex of %s into %s returned no task", source, dest)
+	}
+	r.logger.InfoContext(ctx, "started reindex", "kind", r.kind, "source", source, "dest", dest, "task", started.Task)
+
+	ticker := time.NewTicker(ReindexPollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := r.reindexTask(ctx, started.Task, source, dest)
+		if done {
+			return err
+		}
+		if err != nil {
+			r.cancelTask(started.Task)
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			r.cancelTask(started.Task)
+			return ctx.Err()
+		}
+	}
+}
+
+// reindexTask reports whether the reindex task has completed, and its
+// failures if it has.
+func (r *Repository[T]) reindexTask(ctx context.Context, task, source, dest string) (bool, error) {
+	res, err := r.client.Tasks.Get(task, r.client.Tasks.Get.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("error getting reindex task %s: %w", task, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return false, fmt.Errorf("failed to get reindex task %s: %w", task, eserrors.FromResponse(res))
+	}
+
+	var body struct {
+		Completed bool            `json:"completed"`
+		Error     json.RawMessage `json:"error"`
+		Response  struct {
+			Failures []json.RawMessage `json:"failures"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("error parsing reindex task response: %w", err)
+	}
+	switch {
+	case !body.Completed:
+		return false, nil
+	case len(body.Error) > 0:
+		return true, fmt.Errorf("reindex of %s into %s failed: %s", source, dest, body.Error)
+	case len(body.Response.Failures) > 0:
+		return true, fmt.Errorf("reindex of %s into %s had %d failures, first: %s", source, dest, len(body.Response.Failures), body.Response.Failures[0])
+	}
+	return true, nil
+}
+
+// cancelTask cancels a task whose caller gave up on it.
+func (r *Repository[T]) cancelTask(task string) {
+	res, err := r.client.Tasks.Cancel(r.client.Tasks.Cancel.WithTaskID(task))
+	if err != nil {
+		r.logger.Warn("failed to cancel task", "task", task, "error", err)
+		return
+	}
+	res.Body.Close()
+}
+
+func (r *Repository[T]) count(ctx context.Context, index string) (int64, error) {
+	res, err := r.client.Count(
+		r.client.Count.WithContext(ctx),
+		r.client.Count.WithIndex(index),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error counting documents in %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("failed to count documents in %s: %w", index, eserrors.FromResponse(res))
+	}
+
+	var body struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("error parsing count response: %w", err)
+	}
+	return body.Count, nil
+}
+
+func (r *Repository[T]) deleteIndex(ctx context.Context, index string) error {
+	res, err := r.client.Indices.Delete([]string{index}, r.client.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error deleting index %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("failed to delete index %s: %w", index, eserrors.FromResponse(res))
+	}
+	return nil
+}
+
+func (r *Repository[T]) updateAliases(ctx context.Context, actions []map[string]interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("error marshalling alias actions: %w", err)
+	}
+
+	res, err := r.client.Indices.UpdateAliases(
+		bytes.NewReader(data),
+		r.client.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating aliases: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("failed to update aliases: %w", eserrors.FromResponse(res))
+	}
+	return nil
+}
@@ -1,208 +1,137 @@
 package index
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-var motoMapping = []byte(`
-{
-  "mappings": {
-    "properties": {
-      "id": { "type": "long" },
-      "user_id": { "type": "long" },
-      "user_name": { "type": "keyword" },
-      "stock_id": { "type": "long" },
-      "store_name": { "type": "keyword" },
-      "body_id": { "type": "long" },
-      "body_name_tm": { "type": "keyword" },
-      "body_name_en": { "type": "keyword" },
-      "body_name_ru": { "type": "keyword" },
-      "brand_id": { "type": "long" },
-      "brand_name": { "type": "keyword" },
-      "model_id": { "type": "long" },
-      "model_name": { "type": "keyword" },
-      "type_motorcycles": { "type": "keyword" },
-      "year": { "type": "long" },
-      "price": { "type": "long" },
-      "volume": { "type": "long" },
-      "engine_type": { "type": "keyword" },
-      "number_of_clock_cycles": { "type": "long" },
-      "mileage": { "type": "long" },
-      "air_type": { "type": "keyword" },
-      "color": { "type": "keyword" },
-      "vin": { "type": "keyword" },
-      "description": { "type": "text" },
-      "city_id": { "type": "long" },
-      "city_name_tm": { "type": "keyword" },
-      "city_name_en": { "type": "keyword" },
-      "city_name_ru": { "type": "keyword" },
-      "name": { "type": "keyword" },
-      "mail": { "type": "keyword" },
-      "phone_number": { "type": "keyword" },
-      "is_comment": { "type": "boolean" },
-      "is_exchange": { "type": "boolean" },
-      "is_credit": { "type": "boolean" },
-      "images": { "type": "object", "enabled": true },
-      "status": { "type": "keyword" },
-      "created_at": { "type": "date" },
-      "updated_at": { "type": "date" }
-    }
-  }
-}
-`)
+// motoMapping is generated from the json and es tags of models.Moto.
+var motoMapping = mustMapping(models.Moto{})
+
+// NewMotoRepository returns a repository for moto documents stored in index.
+func NewMotoRepository(client *elasticsearch.Client, index string) *Repository[models.Moto] {
+	return NewRepository[models.Moto](client, index, "moto", motoMapping)
+}
 
 func EnsureMotoIndex(client *elasticsearch.Client, index string) error {
-	res, err := client.Indices.Exists([]string{index})
-	if err != nil {
-		return fmt.Errorf("error checking index: %v", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		fmt.Println("Using existing moto index:", index)
-		return nil
-	}
-
-	res, err = client.Indices.Create(
-		index,
-		client.Indices.Create.WithBody(bytes.NewReader(motoMapping)),
-	)
-	if err != nil {
-		return fmt.Errorf("error creating moto index %s: %v", index, err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("failed to create moto index %s: %s", index, res.String())
-	}
-
-	fmt.Println("Moto index created successfully:", index)
-	return nil
+	return EnsureMotoIndexContext(context.Background(), client, index)
+}
+
+// EnsureMotoIndexContext creates the moto index if it does not exist.
+func EnsureMotoIndexContext(ctx context.Context, client *elasticsearch.Client, index string) error {
+	return NewMotoRepository(client, index).Ensure(ctx)
+}
+
+// GetMoto reads the moto with the given ID. See Repository.Get.
+func GetMoto(ctx context.Context, client *elasticsearch.Client, index string, motoID int64) (*GetResult[models.Moto], error) {
+	return NewMotoRepository(client, index).Get(ctx, motoID)
+}
+
+// MultiGetMotos reads the motos with the given IDs in a single request.
+// See Repository.MultiGet.
+func MultiGetMotos(ctx context.Context, client *elasticsearch.Client, index string, motoIDs []int64) ([]GetResult[models.Moto], error) {
+	return NewMotoRepository(client, index).MultiGet(ctx, motoIDs)
 }
 
 func IndexMoto(client *elasticsearch.Client, index string, moto *models.Moto) error {
-	data, err := json.Marshal(moto)
-	if err != nil {
-		return err
-	}
-
-	res, err := client.Index(
-		index,
-		bytes.NewReader(data),
-		client.Index.WithDocumentID(fmt.Sprintf("%d", moto.Id)),
-		client.Index.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error indexing moto ID=%d: %s", moto.Id, res.String())
-	}
-
-	fmt.Printf("Moto ID=%d indexed successfully\n", moto.Id)
-	return nil
+	return IndexMotoContext(context.Background(), client, index, moto)
+}
+
+// IndexMotoContext stores moto, replacing any existing document with the same ID.
+func IndexMotoContext(ctx context.Context, client *elasticsearch.Client, index string, moto *models.Moto) error {
+	return NewMotoRepository(client, index).Index(ctx, moto)
 }
 
 func UpdateMoto(client *elasticsearch.Client, index string, moto *models.Moto) error {
-	data, err := json.Marshal(map[string]interface{}{
-		"doc":           moto,
-		"doc_as_upsert": true,
-	})
-	if err != nil {
-		return err
-	}
-
-	res, err := client.Update(
-		index,
-		fmt.Sprintf("%d", moto.Id),
-		bytes.NewReader(data),
-		client.Update.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error updating moto ID=%d: %s", moto.Id, res.String())
-	}
-
-	fmt.Printf("Moto ID=%d updated successfully\n", moto.Id)
-	return nil
+	return UpdateMotoContext(context.Background(), client, index, moto)
+}
+
+// UpdateMotoContext merges moto into the stored document, creating it if missing.
+func UpdateMotoContext(ctx context.Context, client *elasticsearch.Client, index string, moto *models.Moto) error {
+	return NewMotoRepository(client, index).Update(ctx, moto)
+}
+
+// UpdateMotoIf merges moto into the stored document if it is still at
+// revision v. See Repository.UpdateIf.
+func UpdateMotoIf(ctx context.Context, client *elasticsearch.Client, index string, moto *models.Moto, v Version) (*Version, error) {
+	return NewMotoRepository(client, index).UpdateIf(ctx, moto, v)
+}
+
+// IndexMotoVersioned stores moto unless the stored document has a newer
+// UpdatedAt. See Repository.IndexVersioned.
+func IndexMotoVersioned(ctx context.Context, client *elasticsearch.Client, index string, moto *models.Moto) error {
+	return NewMotoRepository(client, index).IndexVersioned(ctx, moto)
+}
+
+// BulkUpdateMotosIf partially updates the motos still at their revision in
+// versions. See Repository.BulkUpdateIf.
+func BulkUpdateMotosIf(ctx context.Context, client *elasticsearch.Client, index string, motos []models.Moto, versions map[int64]Version) (*BulkResult, error) {
+	return NewMotoRepository(client, index).BulkUpdateIf(ctx, motos, versions)
+}
+
+// BulkIndexMotosVersioned stores the motos not older than the stored
+// documents. See Repository.BulkIndexVersioned.
+func BulkIndexMotosVersioned(ctx context.Context, client *elasticsearch.Client, index string, motos []models.Moto) (*BulkResult, error) {
+	return NewMotoRepository(client, index).BulkIndexVersioned(ctx, motos)
+}
+
+// UpdateMotoFields sets only the named fields of the stored moto, e.g.
+// {"price": 15000, "status": "accepted"}. See Repository.UpdateFields.
+func UpdateMotoFields(ctx context.Context, client *elasticsearch.Client, index string, motoID int64, fields map[string]interface{}) error {
+	return NewMotoRepository(client, index).UpdateFields(ctx, motoID, fields)
+}
+
+// UpdateMotoScript runs a painless script against the stored moto.
+func UpdateMotoScript(ctx context.Context, client *elasticsearch.Client, index string, motoID int64, script Script) error {
+	return NewMotoRepository(client, index).UpdateScript(ctx, motoID, script)
+}
+
+// IncrementMotoField adds delta to a counter of the stored moto, such as
+// view_count or favorites.
+func IncrementMotoField(ctx context.Context, client *elasticsearch.Client, index string, motoID int64, field string, delta int64) error {
+	return NewMotoRepository(client, index).Increment(ctx, motoID, field, delta)
 }
 
 func DeleteMoto(client *elasticsearch.Client, index string, motoID int64) error {
-	res, err := client.Delete(
-		index,
-		fmt.Sprintf("%d", motoID),
-		client.Delete.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error deleting moto ID=%d: %s", motoID, res.String())
-	}
-
-	fmt.Printf("Moto ID=%d deleted successfully\n", motoID)
-	return nil
-}
-
-func BulkUpdateMotos(client *elasticsearch.Client, index string, motos []models.Moto) error {
-	var buf bytes.Buffer
-
-	for _, moto := range motos {
-		meta := []byte(fmt.Sprintf(`{ "update": { "_index": "%s", "_id": "%d" } }%s`, index, moto.Id, "\n"))
-		doc, err := json.Marshal(map[string]interface{}{"doc": moto})
-		if err != nil {
-			return err
-		}
-		doc = append(doc, "\n"...)
-		buf.Write(meta)
-		buf.Write(doc)
-	}
-
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("bulk update error: %s", res.String())
-	}
-
-	fmt.Println("Bulk update successful")
-	return nil
-}
-
-func BulkDeleteMotos(client *elasticsearch.Client, index string, motoIDs []int64) error {
-	var buf bytes.Buffer
-
-	for _, id := range motoIDs {
-		meta := []byte(fmt.Sprintf(`{ "delete": { "_index": "%s", "_id": "%d" } }%s`, index, id, "\n"))
-		buf.Write(meta)
-	}
-
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("bulk delete error: %s", res.String())
-	}
-
-	fmt.Println("Bulk delete successful")
-	return nil
+	return DeleteMotoContext(context.Background(), client, index, motoID)
+}
+
+// DeleteMotoContext removes the moto with the given ID.
+func DeleteMotoContext(ctx context.Context, client *elasticsearch.Client, index string, motoID int64) error {
+	return NewMotoRepository(client, index).Delete(ctx, motoID)
+}
+
+func BulkUpdateMotos(client *elasticsearch.Client, index string, motos []models.Moto) (*BulkResult, error) {
+	return BulkUpdateMotosContext(context.Background(), client, index, motos)
+}
+
+// BulkUpdateMotosContext partially updates motos in a single bulk request.
+func BulkUpdateMotosContext(ctx context.Context, client *elasticsearch.Client, index string, motos []models.Moto) (*BulkResult, error) {
+	return NewMotoRepository(client, index).BulkUpdate(ctx, motos)
+}
+
+func BulkDeleteMotos(client *elasticsearch.Client, index string, motoIDs []int64) (*BulkResult, error) {
+	return BulkDeleteMotosContext(context.Background(), client, index, motoIDs)
+}
+
+// BulkDeleteMotosContext removes the motos with the given IDs in a single bulk request.
+func BulkDeleteMotosContext(ctx context.Context, client *elasticsearch.Client, index string, motoIDs []int64) (*BulkResult, error) {
+	return NewMotoRepository(client, index).BulkDelete(ctx, motoIDs)
+}
+
+func DeleteMotosByUserID(client *elasticsearch.Client, index string, userID int64) error {
+	return DeleteMotosByUserIDContext(context.Background(), client, index, userID)
+}
+
+// DeleteMotosByUserIDContext removes every moto owned by userID.
+func DeleteMotosByUserIDContext(ctx context.Context, client *elasticsearch.Client, index string, userID int64) error {
+	return NewMotoRepository(client, index).DeleteByUserID(ctx, userID)
+}
+
+// MigrateMotoIndex moves the moto alias to a new versioned index created with
+// the current moto mapping. See Repository.Migrate.
+func MigrateMotoIndex(ctx context.Context, client *elasticsearch.Client, alias string, opts MigrateOptions) (*MigrateResult, error) {
+	return NewMotoRepository(client, alias).Migrate(ctx, opts)
 }
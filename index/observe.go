@@ -0,0 +1,50 @@
+package index
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Hajymuhammet/elasticsearch-package/index")
+
+// operation tracks one index operation for tracing and metrics. Its span is
+// the parent of the transport spans of the requests made with its context.
+type operation struct {
+	name  string
+	index string
+	start time.Time
+	span  trace.Span
+}
+
+// startOperation starts a span named index.<name> with the vehicle kind,
+// the index and attrs as attributes. kind may be empty for mixed batches.
+func startOperation(ctx context.Context, name, kind, index string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	attrs = append(attrs, attribute.String("elasticsearch.index", index))
+	if kind != "" {
+		attrs = append(attrs, attribute.String("vehicle.type", kind))
+	}
+	ctx, span := tracer.Start(ctx, "index."+name, trace.WithAttributes(attrs...))
+	return ctx, &operation{name: name, index: index, start: time.Now(), span: span}
+}
+
+// took returns the time elapsed since the operation started.
+func (op *operation) took() time.Duration {
+	return time.Since(op.start)
+}
+
+// end records the operation with the installed metrics.Recorder and ends
+// its span, marking it failed if err is not nil.
+func (op *operation) end(err error) {
+	metrics.Get().ObserveOperation(op.name, op.index, op.took(), err)
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+	}
+	op.span.End()
+}
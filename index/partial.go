@@ -0,0 +1,94 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// updateRetryOnConflict is how often Elasticsearch re-runs a field or
+// script update that raced with another write to the same document.
+const updateRetryOnConflict = 3
+
+// Script is a painless script run against a stored document by
+// UpdateScript. The document source is ctx._source; values are passed in
+// Params so the compiled script can be cached.
+type Script struct {
+	Source string
+	Params map[string]interface{}
+}
+
+// incrementScript adds params.delta to params.field, treating a missing
+// field as 0.
+const incrementScript = `if (ctx._source[params.field] == null) { ctx._source[params.field] = params.delta } else { ctx._source[params.field] += params.delta }`
+
+// UpdateFields sets only the named fields of the stored document and
+// leaves the others untouched. Names are the json field names of T; a nil
+// value stores null. A missing document fails with an error matching
+// eserrors.ErrNotFound.
+func (r *Repository[T]) UpdateFields(ctx context.Context, id int64, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := r.checkFields(fields); err != nil {
+		return fmt.Errorf("error updating %s ID=%d: %w", r.kind, id, err)
+	}
+	_, err := r.update(ctx, "update_fields", id, map[string]interface{}{"doc": fields},
+		r.client.Update.WithRetryOnConflict(updateRetryOnConflict),
+	)
+	return err
+}
+
+// UpdateScript runs script against the stored document. A missing document
+// fails with an error matching eserrors.ErrNotFound.
+func (r *Repository[T]) UpdateScript(ctx context.Context, id int64, script Script) error {
+	if script.Source == "" {
+		return fmt.Errorf("error updating %s ID=%d: empty script", r.kind, id)
+	}
+	spec := map[string]interface{}{
+		"lang":   "painless",
+		"source": script.Source,
+	}
+	// Elasticsearch rejects "params": null.
+	if len(script.Params) > 0 {
+		spec["params"] = script.Params
+	}
+	_, err := r.update(ctx, "update_script", id, map[string]interface{}{"script": spec},
+		r.client.Update.WithRetryOnConflict(updateRetryOnConflict),
+	)
+	return err
+}
+
+// Increment adds delta, which may be negative, to a numeric counter such
+// as view_count or favorites. The field does not need to exist in T or in
+// the stored document.
+func (r *Repository[T]) Increment(ctx context.Context, id int64, field string, delta int64) error {
+	if field == "" {
+		return fmt.Errorf("error updating %s ID=%d: empty field name", r.kind, id)
+	}
+	return r.UpdateScript(ctx, id, Script{
+		Source: incrementScript,
+		Params: map[string]interface{}{"field": field, "delta": delta},
+	})
+}
+
+// checkFields returns an error if a name in fields is not a json field of
+// T, so that typos do not add stray fields to the index.
+func (r *Repository[T]) checkFields(fields map[string]interface{}) error {
+	known := map[string]bool{}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonFieldName(t.Field(i)); name != "" {
+			known[name] = true
+		}
+	}
+	for name := range fields {
+		if !known[name] {
+			return fmt.Errorf("unknown field %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateScriptBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		script Script
+		want   map[string]interface{}
+	}{
+		{
+			name:   "nil params",
+			script: Script{Source: "ctx._source.status = 'sold'"},
+			want:   map[string]interface{}{"lang": "painless", "source": "ctx._source.status = 'sold'"},
+		},
+		{
+			name:   "empty params",
+			script: Script{Source: "ctx._source.status = 'sold'", Params: map[string]interface{}{}},
+			want:   map[string]interface{}{"lang": "painless", "source": "ctx._source.status = 'sold'"},
+		},
+		{
+			name:   "params",
+			script: Script{Source: "ctx._source.status = params.status", Params: map[string]interface{}{"status": "sold"}},
+			want: map[string]interface{}{
+				"lang":   "painless",
+				"source": "ctx._source.status = params.status",
+				"params": map[string]interface{}{"status": "sold"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				path string
+				body map[string]map[string]interface{}
+			)
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				path = r.Method + " " + r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("invalid request body: %v", err)
+				}
+				io.WriteString(w, `{"_index":"cars","_id":"7","result":"updated","_seq_no":3,"_primary_term":1}`)
+			})
+
+			if err := NewCarRepository(client, "cars").UpdateScript(context.Background(), 7, tt.script); err != nil {
+				t.Fatal(err)
+			}
+			if path != "POST /cars/_update/7" {
+				t.Errorf("request = %s, want POST /cars/_update/7", path)
+			}
+			if !reflect.DeepEqual(body["script"], tt.want) {
+				t.Errorf("script = %v, want %v", body["script"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/Hajymuhammet/elasticsearch-package/metrics"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Document is implemented by every model stored in a vehicle index.
+type Document interface {
+	DocumentID() int64
+}
+
+// Repository implements the index operations shared by every vehicle kind.
+// A new kind only needs a model implementing Document and a mapping.
+type Repository[T Document] struct {
+	client  *elasticsearch.Client
+	index   string
+	kind    string
+	mapping []byte
+	logger  *slog.Logger
+}
+
+// NewRepository returns a repository storing documents of type T in index.
+// kind is a short name such as "car" used in messages.
+func NewRepository[T Document](client *elasticsearch.Client, index, kind string, mapping []byte) *Repository[T] {
+	return &Repository[T]{
+		client:  client,
+		index:   index,
+		kind:    kind,
+		mapping: mapping,
+		logger:  discardLogger,
+	}
+}
+
+// WithLogger returns a copy of the repository that logs to l. Operations
+// log at debug level per document and at info level for index, alias and
+// bulk changes, with index, doc_id, took and status attributes. Without a
+// logger, as for the package-level functions such as IndexCar, logs are
+// discarded.
+func (r *Repository[T]) WithLogger(l *slog.Logger) *Repository[T] {
+	if l == nil {
+		l = discardLogger
+	}
+	c := *r
+	c.logger = l
+	return &c
+}
+
+// IndexName returns the name of the index the repository writes to.
+func (r *Repository[T]) IndexName() string {
+	return r.index
+}
+
+// Ensure creates the index with the repository mapping if it does not exist.
+func (r *Repository[T]) Ensure(ctx context.Context) (err error) {
+	ctx, op := startOperation(ctx, "ensure", r.kind, r.index)
+	defer func() { op.end(err) }()
+	res, err := r.client.Indices.Exists(
+		[]string{r.index},
+		r.client.Indices.Exists.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("error checking if %s index exists: %w", r.kind, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 200 {
+		r.logger.DebugContext(ctx, "using existing index", "kind", r.kind, "index", r.index)
+		return nil
+	}
+
+	res, err = r.client.Indices.Create(
+		r.index,
+		r.client.Indices.Create.WithContext(ctx),
+		r.client.Indices.Create.WithBody(bytes.NewReader(r.mapping)),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating %s index %s: %w", r.kind, r.index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("failed to create %s index %s: %w", r.kind, r.index, eserrors.FromResponse(res))
+	}
+
+	r.logger.InfoContext(ctx, "created index", "kind", r.kind, "index", r.index, "status", res.StatusCode)
+	return nil
+}
+
+// Index stores doc, replacing any existing document with the same ID.
+func (r *Repository[T]) Index(ctx context.Context, doc *T) error {
+	return r.indexDoc(ctx, "index", doc, 0)
+}
+
+// IndexVersioned stores doc only if its external version is newer than the
+// stored one, so out-of-order writes cannot replace newer data. T must
+// implement Versioned. A stale doc fails with an error matching
+// eserrors.ErrConflict. A doc finding the same version stored is not an
+// error if the stored document is identical, e.g. after a retried request;
+// a different document with the same version, such as a second change made
+// in the same millisecond, is a conflict. Documents written by
+// IndexVersioned should not be written by Index or Update, which do not
+// check the version.
+func (r *Repository[T]) IndexVersioned(ctx context.Context, doc *T) error {
+	version, err := documentVersion(*doc)
+	if err != nil {
+		return fmt.Errorf("error indexing %s ID=%d: %w", r.kind, (*doc).DocumentID(), err)
+	}
+	return r.indexDoc(ctx, "index_versioned", doc, version)
+}
+
+// indexDoc stores doc, with an external version unless version is 0.
+func (r *Repository[T]) indexDoc(ctx context.Context, name string, doc *T, version int64) (err error) {
+	id := (*doc).DocumentID()
+	ctx, op := startOperation(ctx, name, r.kind, r.index, attribute.Int64("elasticsearch.doc_id", id))
+	defer func() { op.end(err) }()
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s ID=%d: %w", r.kind, id, err)
+	}
+
+	opts := []func(*esapi.IndexRequest){
+		r.client.Index.WithContext(ctx),
+		r.client.Index.WithDocumentID(fmt.Sprintf("%d", id)),
+		r.client.Index.WithRefresh("wait_for"),
+	}
+	if version > 0 {
+		opts = append(opts,
+			r.client.Index.WithVersion(int(version)),
+			r.client.Index.WithVersionType(externalVersionType),
+		)
+	}
+
+	res, err := r.client.Index(r.index, bytes.NewReader(data), opts...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		e := eserrors.FromResponse(res).(*eserrors.Error)
+		if version > 0 && alreadyAtVersion(e.Type, e.Reason, version) {
+			items := []bulkItem{{ID: id, Status: e.Status, Error: &BulkItemError{ID: id, Status: e.Status, Type: e.Type, Reason: e.Reason}}}
+			writes := []versionedWrite{{Index: r.index, Version: version, Source: data}}
+			if err := acceptRepeatedWrites(ctx, r.client, items, writes); err != nil {
+				r.logger.WarnContext(ctx, "error comparing document at version", "kind", r.kind, "index", r.index, "doc_id", id, "version", version, "error", err)
+			} else if items[0].Error == nil {
+				r.logger.DebugContext(ctx, "document already at version", "kind", r.kind, "index", r.index, "doc_id", id, "version", version)
+				return nil
+			}
+		}
+		return fmt.Errorf("error indexing %s ID=%d: %w", r.kind, id, e)
+	}
+
+	r.logger.DebugContext(ctx, "indexed document", "kind", r.kind, "index", r.index, "doc_id", id, "took", op.took(), "status", res.StatusCode)
+	return nil
+}
+
+// Update merges doc into the stored document, creating it if missing.
+func (r *Repository[T]) Update(ctx context.Context, doc *T) error {
+	_, err := r.update(ctx, "update", (*doc).DocumentID(), map[string]interface{}{
+		"doc":           doc,
+		"doc_as_upsert": true,
+	})
+	return err
+}
+
+// UpdateIf merges doc into the stored document if it is still at revision
+// v, and returns the new revision. If the document changed since, it fails
+// with an error matching eserrors.ErrConflict; a missing document fails
+// with one matching eserrors.ErrNotFound.
+func (r *Repository[T]) UpdateIf(ctx context.Context, doc *T, v Version) (*Version, error) {
+	return r.update(ctx, "update_if", (*doc).DocumentID(), map[string]interface{}{"doc": doc},
+		r.client.Update.WithIfSeqNo(int(v.SeqNo)),
+		r.client.Update.WithIfPrimaryTerm(int(v.PrimaryTerm)),
+	)
+}
+
+// update sends body to the update API and returns the new revision of the
+// document. opts must not set a context.
+func (r *Repository[T]) update(ctx context.Context, name string, id int64, body map[string]interface{}, opts ...func(*esapi.UpdateRequest)) (_ *Version, err error) {
+	ctx, op := startOperation(ctx, name, r.kind, r.index, attribute.Int64("elasticsearch.doc_id", id))
+	defer func() { op.end(err) }()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling %s ID=%d: %w", r.kind, id, err)
+	}
+
+	res, err := r.client.Update(
+		r.index,
+		fmt.Sprintf("%d", id),
+		bytes.NewReader(data),
+		append([]func(*esapi.UpdateRequest){
+			r.client.Update.WithContext(ctx),
+			r.client.Update.WithRefresh("wait_for"),
+		}, opts...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error updating %s ID=%d: %w", r.kind, id, eserrors.FromResponse(res))
+	}
+
+	var version Version
+	if err := json.NewDecoder(res.Body).Decode(&version); err != nil {
+		return nil, fmt.Errorf("error parsing update response: %w", err)
+	}
+
+	r.logger.DebugContext(ctx, "updated document", "kind", r.kind, "index", r.index, "doc_id", id, "took", op.took(), "status", res.StatusCode)
+	return &version, nil
+}
+
+// Delete removes the document with the given ID.
+func (r *Repository[T]) Delete(ctx context.Context, id int64) (err error) {
+	ctx, op := startOperation(ctx, "delete", r.kind, r.index, attribute.Int64("elasticsearch.doc_id", id))
+	defer func() { op.end(err) }()
+	res, err := r.client.Delete(
+		r.index,
+		fmt.Sprintf("%d", id),
+		r.client.Delete.WithContext(ctx),
+		r.client.Delete.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error deleting %s ID=%d: %w", r.kind, id, eserrors.FromResponse(res))
+	}
+
+	r.logger.DebugContext(ctx, "deleted document", "kind", r.kind, "index", r.index, "doc_id", id, "took", op.took(), "status", res.StatusCode)
+	return nil
+}
+
+// BulkUpdate partially updates docs in a single bulk request, re-sending
+// items rejected with 429 according to DefaultBulkRetry. If any item
+// fails the returned error wraps ErrBulkItemsFailed and the BulkResult lists
+// the failed IDs.
+func (r *Repository[T]) BulkUpdate(ctx context.Context, docs []T) (*BulkResult, error) {
+	return r.bulkUpdate(ctx, "bulk_update", docs, nil)
+}
+
+// BulkUpdateIf partially updates each of docs if it is still at the
+// revision in versions, keyed by document ID. Stale documents are reported
+// as failed items matching eserrors.ErrConflict; the others are updated.
+func (r *Repository[T]) BulkUpdateIf(ctx context.Context, docs []T, versions map[int64]Version) (*BulkResult, error) {
+	for _, doc := range docs {
+		if _, ok := versions[doc.DocumentID()]; !ok {
+			return nil, fmt.Errorf("no version for %s ID=%d", r.kind, doc.DocumentID())
+		}
+	}
+	return r.bulkUpdate(ctx, "bulk_update_if", docs, versions)
+}
+
+func (r *Repository[T]) bulkUpdate(ctx context.Context, name string, docs []T, versions map[int64]Version) (_ *BulkResult, err error) {
+	if len(docs) == 0 {
+		return &BulkResult{}, nil
+	}
+	ctx, op := startOperation(ctx, name, r.kind, r.index, attribute.Int("elasticsearch.doc_count", len(docs)))
+	defer func() { op.end(err) }()
+
+	chunks := make([][]byte, 0, len(docs))
+	for _, doc := range docs {
+		var v *Version
+		if version, ok := versions[doc.DocumentID()]; ok {
+			v = &version
+		}
+		meta, err := actionLine(ActionUpdate, r.index, doc.DocumentID(), v, 0)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(map[string]interface{}{"doc": doc})
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling %s ID=%d: %w", r.kind, doc.DocumentID(), err)
+		}
+		data = append(data, "\n"...)
+		chunks = append(chunks, append(meta, data...))
+	}
+	return r.bulk(ctx, op, "update", chunks, nil)
+}
+
+// BulkIndexVersioned stores docs in a single bulk request, skipping those
+// whose stored document has the same or a newer external version. T must
+// implement Versioned. Stale documents are reported as failed items
+// matching eserrors.ErrConflict. Documents finding the same version stored
+// are reported as succeeded if the stored document is identical and as
+// conflicts otherwise; see IndexVersioned.
+func (r *Repository[T]) BulkIndexVersioned(ctx context.Context, docs []T) (_ *BulkResult, err error) {
+	if len(docs) == 0 {
+		return &BulkResult{}, nil
+	}
+	ctx, op := startOperation(ctx, "bulk_index_versioned", r.kind, r.index, attribute.Int("elasticsearch.doc_count", len(docs)))
+	defer func() { op.end(err) }()
+
+	chunks := make([][]byte, 0, len(docs))
+	writes := make([]versionedWrite, 0, len(docs))
+	for _, doc := range docs {
+		version, err := documentVersion(doc)
+		if err != nil {
+			return nil, fmt.Errorf("error indexing %s ID=%d: %w", r.kind, doc.DocumentID(), err)
+		}
+		meta, err := actionLine(ActionIndex, r.index, doc.DocumentID(), nil, version)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling %s ID=%d: %w", r.kind, doc.DocumentID(), err)
+		}
+		writes = append(writes, versionedWrite{Index: r.index, Version: version, Source: data})
+		chunks = append(chunks, append(append(meta, data...), '\n'))
+	}
+	return r.bulk(ctx, op, "index", chunks, writes)
+}
+
+// BulkDelete removes the documents with the given IDs in a single bulk request.
+// Item failures are reported as for BulkUpdate.
+func (r *Repository[T]) BulkDelete(ctx context.Context, ids []int64) (_ *BulkResult, err error) {
+	if len(ids) == 0 {
+		return &BulkResult{}, nil
+	}
+	ctx, op := startOperation(ctx, "bulk_delete", r.kind, r.index, attribute.Int("elasticsearch.doc_count", len(ids)))
+	defer func() { op.end(err) }()
+
+	chunks := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		meta, err := actionLine(ActionDelete, r.index, id, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, meta)
+	}
+	return r.bulk(ctx, op, "delete", chunks, nil)
+}
+
+// bulk sends the chunks of a bulk operation and reports the item outcomes.
+// writes, if not nil, are the externally versioned writes of the chunks;
+// see acceptRepeatedWrites.
+func (r *Repository[T]) bulk(ctx context.Context, op *operation, action string, chunks [][]byte, writes []versionedWrite) (*BulkResult, error) {
+	items, err := sendBulk(ctx, r.client, chunks, DefaultBulkRetry)
+	if err != nil {
+		return nil, fmt.Errorf("bulk %s error: %w", action, err)
+	}
+	if writes != nil {
+		if err := acceptRepeatedWrites(ctx, r.client, items, writes); err != nil {
+			r.logger.WarnContext(ctx, "error comparing documents at version", "kind", r.kind, "index", r.index, "error", err)
+		}
+	}
+
+	result := newBulkResult(items)
+	metrics.Get().AddBulkItems(r.index, len(result.Succeeded), len(result.Failed))
+	op.span.SetAttributes(attribute.Int("elasticsearch.bulk.failed", len(result.Failed)))
+	if err := result.err(action, r.kind); err != nil {
+		r.logger.WarnContext(ctx, "bulk "+action+" had failures", "kind", r.kind, "index", r.index, "failed", len(result.Failed), "succeeded", len(result.Succeeded), "took", op.took())
+		return result, err
+	}
+
+	r.logger.InfoContext(ctx, "bulk "+action+" succeeded", "kind", r.kind, "index", r.index, "count", len(chunks), "took", op.took())
+	return result, nil
+}
+
+// DeleteByUserID removes every document owned by userID.
+func (r *Repository[T]) DeleteByUserID(ctx context.Context, userID int64) (err error) {
+	ctx, op := startOperation(ctx, "delete_by_user", r.kind, r.index, attribute.Int64("vehicle.user_id", userID))
+	defer func() { op.end(err) }()
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{
+				"user_id": userID,
+			},
+		},
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("error marshalling query: %w", err)
+	}
+
+	res, err := r.client.DeleteByQuery(
+		[]string{r.index},
+		bytes.NewReader(data),
+		r.client.DeleteByQuery.WithContext(ctx),
+		r.client.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting by user_id=%d: %w", userID, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("delete by query failed for user_id=%d: %w", userID, eserrors.FromResponse(res))
+	}
+
+	r.logger.InfoContext(ctx, "deleted documents by user", "kind", r.kind, "index", r.index, "user_id", userID, "took", op.took(), "status", res.StatusCode)
+	return nil
+}
@@ -1,226 +1,137 @@
 package index
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
 
 	"github.com/Hajymuhammet/elasticsearch-package/models"
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-var truckMapping = []byte(`
-{
-  "mappings": {
-    "properties": {
-      "id": { "type": "long" },
-      "user_id": { "type": "long" },
-      "user_name": { "type": "keyword" },
-      "stock_id": { "type": "long" },
-      "store_name": { "type": "keyword" },
-      "body_id": { "type": "long" },
-      "body_name_tm": { "type": "keyword" },
-      "body_name_en": { "type": "keyword" },
-      "body_name_ru": { "type": "keyword" },
-      "brand_id": { "type": "long" },
-      "brand_name": { "type": "keyword" },
-      "model_id": { "type": "long" },
-      "model_name": { "type": "keyword" },
-      "load_capacity": { "type": "keyword" },
-      "price": { "type": "long" },
-      "body_type": { "type": "keyword" },
-      "drive_type": { "type": "keyword" },
-      "transmission": { "type": "keyword" },
-      "engine_type": { "type": "keyword" },
-      "year": { "type": "long" },
-      "seats": { "type": "long" },
-      "cab_type": { "type": "keyword" },
-      "wheel_formula": { "type": "keyword" },
-      "chassis": { "type": "keyword" },
-      "cab_suspension": { "type": "keyword" },
-      "bus_type": { "type": "keyword" },
-      "suspension_type": { "type": "keyword" },
-      "brakes": { "type": "keyword" },
-      "axles": { "type": "long" },
-      "engine_hours": { "type": "long" },
-      "vehicle_type": { "type": "keyword" },
-      "engine_capacity": { "type": "double" },
-      "forklift_type": { "type": "keyword" },
-      "lifting_capacity": { "type": "long" },
-      "mileage": { "type": "long" },
-      "excavator_type": { "type": "keyword" },
-      "bulldozer_type": { "type": "keyword" },
-      "color": { "type": "keyword" },
-      "vin": { "type": "keyword" },
-      "description": { "type": "text" },
-      "city_id": { "type": "long" },
-      "city_name_tm": { "type": "keyword" },
-      "city_name_en": { "type": "keyword" },
-      "city_name_ru": { "type": "keyword" },
-      "name": { "type": "keyword" },
-      "mail": { "type": "keyword" },
-      "phone_number": { "type": "keyword" },
-      "is_comment": { "type": "boolean" },
-      "is_exchange": { "type": "boolean" },
-      "is_credit": { "type": "boolean" },
-      "images": { "type": "object", "enabled": true },
-      "status": { "type": "keyword" },
-      "options": { "type": "long" },
-      "created_at": { "type": "date" },
-      "updated_at": { "type": "date" }
-    }
-  }
-}
-`)
-
-func EnsureTruckIndex(client *elasticsearch.Client, indexName string) error {
-	res, err := client.Indices.Exists([]string{indexName})
-	if err != nil {
-		return fmt.Errorf("error checking if index exists: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		fmt.Println("Using existing index:", indexName)
-		return nil
-	}
-
-	res, err = client.Indices.Create(
-		indexName,
-		client.Indices.Create.WithBody(bytes.NewReader(truckMapping)),
-	)
-	if err != nil {
-		return fmt.Errorf("error while creating truck index: %s: %w", indexName, err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("failed to create truck index %s: %s", indexName, res.String())
-	}
-
-	fmt.Println("Created truck index:", indexName)
-
-	return nil
-}
-
-func IndexTruck(client *elasticsearch.Client, indexName string, truck *models.Truck) error {
-	data, err := json.Marshal(truck)
-	if err != nil {
-		fmt.Println("Error marshalling truck index:", err)
-	}
-
-	res, err := client.Index(
-		indexName,
-		bytes.NewReader(data),
-		client.Index.WithDocumentID(fmt.Sprintf("%d", truck.Id)),
-		client.Index.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error indexing truck ID=%d: %s", truck.Id, res.String())
-	}
-
-	fmt.Printf("Truck ID=%d indexed successfully\n", truck.Id)
-	return nil
+// truckMapping is generated from the json and es tags of models.Truck.
+var truckMapping = mustMapping(models.Truck{})
+
+// NewTruckRepository returns a repository for truck documents stored in index.
+func NewTruckRepository(client *elasticsearch.Client, index string) *Repository[models.Truck] {
+	return NewRepository[models.Truck](client, index, "truck", truckMapping)
+}
+
+func EnsureTruckIndex(client *elasticsearch.Client, index string) error {
+	return EnsureTruckIndexContext(context.Background(), client, index)
+}
+
+// EnsureTruckIndexContext creates the truck index if it does not exist.
+func EnsureTruckIndexContext(ctx context.Context, client *elasticsearch.Client, index string) error {
+	return NewTruckRepository(client, index).Ensure(ctx)
+}
+
+// GetTruck reads the truck with the given ID. See Repository.Get.
+func GetTruck(ctx context.Context, client *elasticsearch.Client, index string, truckID int64) (*GetResult[models.Truck], error) {
+	return NewTruckRepository(client, index).Get(ctx, truckID)
+}
+
+// MultiGetTrucks reads the trucks with the given IDs in a single request.
+// See Repository.MultiGet.
+func MultiGetTrucks(ctx context.Context, client *elasticsearch.Client, index string, truckIDs []int64) ([]GetResult[models.Truck], error) {
+	return NewTruckRepository(client, index).MultiGet(ctx, truckIDs)
+}
+
+func IndexTruck(client *elasticsearch.Client, index string, truck *models.Truck) error {
+	return IndexTruckContext(context.Background(), client, index, truck)
+}
+
+// IndexTruckContext stores truck, replacing any existing document with the same ID.
+func IndexTruckContext(ctx context.Context, client *elasticsearch.Client, index string, truck *models.Truck) error {
+	return NewTruckRepository(client, index).Index(ctx, truck)
 }
 
 func UpdateTruck(client *elasticsearch.Client, index string, truck *models.Truck) error {
-	data, err := json.Marshal(map[string]interface{}{
-		"doc":           truck,
-		"doc_as_upsert": true,
-	})
-	if err != nil {
-		return err
-	}
-
-	res, err := client.Update(
-		index,
-		fmt.Sprintf("%d", truck.Id),
-		bytes.NewReader(data),
-		client.Update.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error updating truck ID=%d: %s", truck.Id, res.String())
-	}
-
-	fmt.Printf("Truck ID=%d updated successfully\n", truck.Id)
-	return nil
+	return UpdateTruckContext(context.Background(), client, index, truck)
+}
+
+// UpdateTruckContext merges truck into the stored document, creating it if missing.
+func UpdateTruckContext(ctx context.Context, client *elasticsearch.Client, index string, truck *models.Truck) error {
+	return NewTruckRepository(client, index).Update(ctx, truck)
+}
+
+// UpdateTruckIf merges truck into the stored document if it is still at
+// revision v. See Repository.UpdateIf.
+func UpdateTruckIf(ctx context.Context, client *elasticsearch.Client, index string, truck *models.Truck, v Version) (*Version, error) {
+	return NewTruckRepository(client, index).UpdateIf(ctx, truck, v)
+}
+
+// IndexTruckVersioned stores truck unless the stored document has a newer
+// UpdatedAt. See Repository.IndexVersioned.
+func IndexTruckVersioned(ctx context.Context, client *elasticsearch.Client, index string, truck *models.Truck) error {
+	return NewTruckRepository(client, index).IndexVersioned(ctx, truck)
+}
+
+// BulkUpdateTrucksIf partially updates the trucks still at their revision in
+// versions. See Repository.BulkUpdateIf.
+func BulkUpdateTrucksIf(ctx context.Context, client *elasticsearch.Client, index string, trucks []models.Truck, versions map[int64]Version) (*BulkResult, error) {
+	return NewTruckRepository(client, index).BulkUpdateIf(ctx, trucks, versions)
+}
+
+// BulkIndexTrucksVersioned stores the trucks not older than the stored
+// documents. See Repository.BulkIndexVersioned.
+func BulkIndexTrucksVersioned(ctx context.Context, client *elasticsearch.Client, index string, trucks []models.Truck) (*BulkResult, error) {
+	return NewTruckRepository(client, index).BulkIndexVersioned(ctx, trucks)
+}
+
+// UpdateTruckFields sets only the named fields of the stored truck, e.g.
+// {"price": 15000, "status": "accepted"}. See Repository.UpdateFields.
+func UpdateTruckFields(ctx context.Context, client *elasticsearch.Client, index string, truckID int64, fields map[string]interface{}) error {
+	return NewTruckRepository(client, index).UpdateFields(ctx, truckID, fields)
+}
+
+// UpdateTruckScript runs a painless script against the stored truck.
+func UpdateTruckScript(ctx context.Context, client *elasticsearch.Client, index string, truckID int64, script Script) error {
+	return NewTruckRepository(client, index).UpdateScript(ctx, truckID, script)
+}
+
+// IncrementTruckField adds delta to a counter of the stored truck, such as
+// view_count or favorites.
+func IncrementTruckField(ctx context.Context, client *elasticsearch.Client, index string, truckID int64, field string, delta int64) error {
+	return NewTruckRepository(client, index).Increment(ctx, truckID, field, delta)
 }
 
 func DeleteTruck(client *elasticsearch.Client, index string, truckID int64) error {
-	res, err := client.Delete(
-		index,
-		fmt.Sprintf("%d", truckID),
-		client.Delete.WithRefresh("wait_for"),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("error deleting truck ID=%d: %s", truckID, res.String())
-	}
-
-	fmt.Printf("Truck ID=%d deleted successfully\n", truckID)
-	return nil
-}
-
-func BulkUpdateTrucks(client *elasticsearch.Client, index string, trucks []models.Truck) error {
-	var buf bytes.Buffer
-
-	for _, truck := range trucks {
-		meta := []byte(fmt.Sprintf(`{ "update": { "_index": "%s", "_id": "%d" } }%s`, index, truck.Id, "\n"))
-		doc, err := json.Marshal(map[string]interface{}{"doc": truck})
-		if err != nil {
-			return err
-		}
-		doc = append(doc, "\n"...)
-		buf.Write(meta)
-		buf.Write(doc)
-	}
-
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("bulk update error: %s", res.String())
-	}
-
-	fmt.Println("Bulk update successful")
-	return nil
-}
-
-func BulkDeleteTrucks(client *elasticsearch.Client, index string, truckIDs []int64) error {
-	var buf bytes.Buffer
-
-	for _, id := range truckIDs {
-		meta := []byte(fmt.Sprintf(`{ "delete": { "_index": "%s", "_id": "%d" } }%s`, index, id, "\n"))
-		buf.Write(meta)
-	}
-
-	res, err := client.Bulk(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("bulk delete error: %s", res.String())
-	}
-
-	fmt.Println("Bulk delete successful")
-	return nil
+	return DeleteTruckContext(context.Background(), client, index, truckID)
+}
+
+// DeleteTruckContext removes the truck with the given ID.
+func DeleteTruckContext(ctx context.Context, client *elasticsearch.Client, index string, truckID int64) error {
+	return NewTruckRepository(client, index).Delete(ctx, truckID)
+}
+
+func BulkUpdateTrucks(client *elasticsearch.Client, index string, trucks []models.Truck) (*BulkResult, error) {
+	return BulkUpdateTrucksContext(context.Background(), client, index, trucks)
+}
+
+// BulkUpdateTrucksContext partially updates trucks in a single bulk request.
+func BulkUpdateTrucksContext(ctx context.Context, client *elasticsearch.Client, index string, trucks []models.Truck) (*BulkResult, error) {
+	return NewTruckRepository(client, index).BulkUpdate(ctx, trucks)
+}
+
+func BulkDeleteTrucks(client *elasticsearch.Client, index string, truckIDs []int64) (*BulkResult, error) {
+	return BulkDeleteTrucksContext(context.Background(), client, index, truckIDs)
+}
+
+// BulkDeleteTrucksContext removes the trucks with the given IDs in a single bulk request.
+func BulkDeleteTrucksContext(ctx context.Context, client *elasticsearch.Client, index string, truckIDs []int64) (*BulkResult, error) {
+	return NewTruckRepository(client, index).BulkDelete(ctx, truckIDs)
+}
+
+func DeleteTrucksByUserID(client *elasticsearch.Client, index string, userID int64) error {
+	return DeleteTrucksByUserIDContext(context.Background(), client, index, userID)
+}
+
+// DeleteTrucksByUserIDContext removes every truck owned by userID.
+func DeleteTrucksByUserIDContext(ctx context.Context, client *elasticsearch.Client, index string, userID int64) error {
+	return NewTruckRepository(client, index).DeleteByUserID(ctx, userID)
+}
+
+// MigrateTruckIndex moves the truck alias to a new versioned index created with
+// the current truck mapping. See Repository.Migrate.
+func MigrateTruckIndex(ctx context.Context, client *elasticsearch.Client, alias string, opts MigrateOptions) (*MigrateResult, error) {
+	return NewTruckRepository(client, alias).Migrate(ctx, opts)
 }
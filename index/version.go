@@ -0,0 +1,178 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// Version identifies a revision of a stored document for optimistic
+// concurrency control. Conditional writes fail with an error matching
+// eserrors.ErrConflict when the document has changed since.
+type Version struct {
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// Versioned is implemented by models that carry an external version, such
+// as a modification time, for IndexVersioned and BulkIndexVersioned.
+// Versions must increase with every change of the document; of two
+// different changes with the same version only the first one written is
+// kept, and the second fails with a conflict.
+type Versioned interface {
+	DocumentVersion() int64
+}
+
+// externalVersionType is the version_type of externally versioned writes:
+// a write succeeds only if its version is greater than the stored one.
+const externalVersionType = "external"
+
+// versionConflict matches the reason of an external version conflict.
+var versionConflict = regexp.MustCompile(`current version \[(\d+)\] is higher or equal to the one provided \[(\d+)\]`)
+
+// alreadyAtVersion reports whether a failed externally versioned write of
+// version found the document stored with exactly that version. This is the
+// outcome of a retried request whose first attempt succeeded, but also of a
+// different change made with the same version; acceptRepeatedWrites tells
+// them apart.
+func alreadyAtVersion(typ, reason string, version int64) bool {
+	if typ != "version_conflict_engine_exception" {
+		return false
+	}
+	m := versionConflict.FindStringSubmatch(reason)
+	return m != nil && m[1] == m[2] && m[2] == strconv.FormatInt(version, 10)
+}
+
+// versionedWrite is the externally versioned write of a bulk item.
+type versionedWrite struct {
+	Index   string
+	Version int64  // 0 if the write is not externally versioned
+	Source  []byte // nil for a delete
+}
+
+// acceptRepeatedWrites clears the error of every item whose write found the
+// document already stored with its version and source, or already deleted,
+// so that a retried write counts as done. An item whose stored document
+// differs keeps its conflict error. writes[i] is the write of items[i].
+func acceptRepeatedWrites(ctx context.Context, client *elasticsearch.Client, items []bulkItem, writes []versionedWrite) error {
+	var repeated []int
+	for i, item := range items {
+		if item.Error != nil && writes[i].Version > 0 && alreadyAtVersion(item.Error.Type, item.Error.Reason, writes[i].Version) {
+			repeated = append(repeated, i)
+		}
+	}
+	if len(repeated) == 0 {
+		return nil
+	}
+
+	docs := make([]map[string]interface{}, len(repeated))
+	for j, i := range repeated {
+		docs[j] = map[string]interface{}{
+			"_index": writes[i].Index,
+			"_id":    strconv.FormatInt(items[i].ID, 10),
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"docs": docs})
+	if err != nil {
+		return fmt.Errorf("error marshalling docs: %w", err)
+	}
+
+	res, err := client.Mget(bytes.NewReader(data), client.Mget.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error getting stored documents: %w", eserrors.FromResponse(res))
+	}
+
+	var body struct {
+		Docs []struct {
+			Found   bool            `json:"found"`
+			Version int64           `json:"_version"`
+			Source  json.RawMessage `json:"_source"`
+			Error   *eserrors.Cause `json:"error"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return fmt.Errorf("error parsing multi get response: %w", err)
+	}
+	if len(body.Docs) != len(repeated) {
+		return fmt.Errorf("multi get response has %d docs, expected %d", len(body.Docs), len(repeated))
+	}
+
+	for j, i := range repeated {
+		doc, w := body.Docs[j], writes[i]
+		switch {
+		case doc.Error != nil:
+		case w.Source == nil && !doc.Found:
+			items[i].Error = nil
+		case w.Source != nil && doc.Found && doc.Version == w.Version && sameJSON(doc.Source, w.Source):
+			items[i].Error = nil
+		}
+	}
+	return nil
+}
+
+// sameJSON reports whether a and b encode the same JSON value.
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if err := decodeJSON(a, &va); err != nil {
+		return false
+	}
+	if err := decodeJSON(b, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// decodeJSON decodes data into v, keeping numbers exact.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// documentVersion returns the external version of doc.
+func documentVersion(doc interface{}) (int64, error) {
+	v, ok := doc.(Versioned)
+	if !ok {
+		return 0, fmt.Errorf("%T does not implement Versioned", doc)
+	}
+	version := v.DocumentVersion()
+	if version <= 0 {
+		return 0, fmt.Errorf("%T has no version", doc)
+	}
+	return version, nil
+}
+
+// actionLine renders the bulk action line of an operation on id. v makes
+// the operation conditional on a revision, external sets an external version.
+func actionLine(action BulkAction, index string, id int64, v *Version, external int64) ([]byte, error) {
+	meta := map[string]interface{}{
+		"_index": index,
+		"_id":    fmt.Sprintf("%d", id),
+	}
+	if v != nil {
+		meta["if_seq_no"] = v.SeqNo
+		meta["if_primary_term"] = v.PrimaryTerm
+	}
+	if external > 0 {
+		meta["version"] = external
+		meta["version_type"] = externalVersionType
+	}
+
+	data, err := json.Marshal(map[string]interface{}{string(action): meta})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling bulk %s ID=%d: %w", action, id, err)
+	}
+	return append(data, '\n'), nil
+}
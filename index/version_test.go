@@ -0,0 +1,139 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/Hajymuhammet/elasticsearch-package/eserrors"
+)
+
+func TestAlreadyAtVersion(t *testing.T) {
+	const conflict = "version_conflict_engine_exception"
+	tests := []struct {
+		name    string
+		typ     string
+		reason  string
+		version int64
+		want    bool
+	}{
+		{"same version", conflict, "[1]: version conflict, current version [1700000000000] is higher or equal to the one provided [1700000000000]", 1700000000000, true},
+		{"newer stored", conflict, "[1]: version conflict, current version [1700000000001] is higher or equal to the one provided [1700000000000]", 1700000000000, false},
+		{"other version sent", conflict, "[1]: version conflict, current version [5] is higher or equal to the one provided [5]", 6, false},
+		{"seq_no conflict", conflict, "[1]: version conflict, required seqNo [3], primary term [1]. current document has seqNo [4] and primary term [1]", 3, false},
+		{"other error", "mapper_parsing_exception", "current version [5] is higher or equal to the one provided [5]", 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alreadyAtVersion(tt.typ, tt.reason, tt.version); got != tt.want {
+				t.Errorf("alreadyAtVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// versionedDoc is a testDoc with an external version.
+type versionedDoc struct {
+	ID      int64  `json:"id"`
+	Color   string `json:"color"`
+	Version int64  `json:"version"`
+}
+
+func (d versionedDoc) DocumentID() int64      { return d.ID }
+func (d versionedDoc) DocumentVersion() int64 { return d.Version }
+
+func TestIndexVersionedSameVersion(t *testing.T) {
+	const conflict = `{"error":{"type":"version_conflict_engine_exception","reason":"[1]: version conflict, current version [7] is higher or equal to the one provided [7]"},"status":409}`
+	tests := []struct {
+		name    string
+		status  int    // of the multi get response
+		stored  string // multi get response
+		wantErr bool
+	}{
+		{"identical document", http.StatusOK, `{"docs":[{"_id":"1","found":true,"_version":7,"_source":{"color":"red","id":1,"version":7}}]}`, false},
+		{"different document", http.StatusOK, `{"docs":[{"_id":"1","found":true,"_version":7,"_source":{"id":1,"color":"blue","version":7}}]}`, true},
+		{"newer version stored since", http.StatusOK, `{"docs":[{"_id":"1","found":true,"_version":8,"_source":{"id":1,"color":"red","version":7}}]}`, true},
+		{"document deleted since", http.StatusOK, `{"docs":[{"_id":"1","found":false}]}`, true},
+		{"multi get failed", http.StatusNotFound, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mget bool
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				if r.URL.Path == "/_mget" {
+					mget = true
+					w.WriteHeader(tt.status)
+					io.WriteString(w, tt.stored)
+					return
+				}
+				w.WriteHeader(http.StatusConflict)
+				io.WriteString(w, conflict)
+			})
+
+			repo := NewRepository[versionedDoc](client, "cars", "car", nil)
+			err := repo.IndexVersioned(context.Background(), &versionedDoc{ID: 1, Color: "red", Version: 7})
+			if !mget {
+				t.Error("stored document not compared")
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("IndexVersioned() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, eserrors.ErrConflict) {
+				t.Errorf("IndexVersioned() error = %v, want eserrors.ErrConflict", err)
+			}
+		})
+	}
+}
+
+func TestAcceptRepeatedWrites(t *testing.T) {
+	conflict := func(id int64, stored, sent string) bulkItem {
+		return bulkItem{ID: id, Status: http.StatusConflict, Error: &BulkItemError{
+			ID: id, Status: http.StatusConflict, Type: "version_conflict_engine_exception",
+			Reason: "version conflict, current version [" + stored + "] is higher or equal to the one provided [" + sent + "]",
+		}}
+	}
+	items := []bulkItem{
+		{ID: 1, Status: http.StatusOK},
+		conflict(2, "5", "5"), // identical
+		conflict(3, "6", "5"), // stale
+		conflict(4, "5", "5"), // different document
+		conflict(5, "5", "5"), // delete, gone
+		conflict(6, "5", "5"), // delete, still stored
+	}
+	writes := []versionedWrite{
+		{Index: "cars", Version: 5, Source: []byte(`{"id":1}`)},
+		{Index: "cars", Version: 5, Source: []byte(`{"id":2,"price":10.5}` + "\n")},
+		{Index: "cars", Version: 5, Source: []byte(`{"id":3}`)},
+		{Index: "cars", Version: 5, Source: []byte(`{"id":4,"color":"red"}`)},
+		{Index: "trucks", Version: 5},
+		{Index: "trucks", Version: 5},
+	}
+
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		io.WriteString(w, `{"docs":[
+			{"_id":"2","found":true,"_version":5,"_source":{"price":10.5,"id":2}},
+			{"_id":"4","found":true,"_version":5,"_source":{"id":4,"color":"blue"}},
+			{"_id":"5","found":false},
+			{"_id":"6","found":true,"_version":5,"_source":{"id":6}}
+		]}`)
+	})
+
+	if err := acceptRepeatedWrites(context.Background(), client, items, writes); err != nil {
+		t.Fatal(err)
+	}
+	const wantBody = `{"docs":[{"_id":"2","_index":"cars"},{"_id":"4","_index":"cars"},{"_id":"5","_index":"trucks"},{"_id":"6","_index":"trucks"}]}`
+	if body != wantBody {
+		t.Errorf("multi get body = %s, want %s", body, wantBody)
+	}
+	for i, wantFailed := range []bool{false, false, true, true, false, true} {
+		if failed := items[i].Error != nil; failed != wantFailed {
+			t.Errorf("item ID=%d failed = %v, want %v", items[i].ID, failed, wantFailed)
+		}
+	}
+}
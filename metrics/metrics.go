@@ -0,0 +1,56 @@
+// Package metrics is the pluggable metrics layer of the index, filter and
+// clients packages. Install a Recorder, such as the Prometheus one in
+// metrics/prom, with SetRecorder; by default nothing is recorded.
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Recorder receives the measurements of the package operations.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// ObserveOperation records one operation, such as "search" or
+	// "bulk_update", on index and whether it failed.
+	ObserveOperation(operation, index string, took time.Duration, err error)
+	// ObserveHits records the number of hits returned by a search on index.
+	ObserveHits(index string, hits int)
+	// AddBulkItems counts the succeeded and failed items of a bulk request.
+	AddBulkItems(index string, succeeded, failed int)
+	// IncRetry counts a retried request; kind is "request" for transport
+	// retries and "bulk_item" for bulk items re-sent after a 429.
+	IncRetry(kind string)
+	// SetCircuitState records the state of the client circuit breaker.
+	SetCircuitState(state string)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) ObserveOperation(string, string, time.Duration, error) {}
+func (nopRecorder) ObserveHits(string, int)                               {}
+func (nopRecorder) AddBulkItems(string, int, int)                         {}
+func (nopRecorder) IncRetry(string)                                       {}
+func (nopRecorder) SetCircuitState(string)                                {}
+
+type holder struct{ Recorder }
+
+var recorder atomic.Pointer[holder]
+
+func init() {
+	SetRecorder(nil)
+}
+
+// SetRecorder installs r for all packages. nil, the default, discards all
+// measurements.
+func SetRecorder(r Recorder) {
+	if r == nil {
+		r = nopRecorder{}
+	}
+	recorder.Store(&holder{r})
+}
+
+// Get returns the installed Recorder.
+func Get() Recorder {
+	return recorder.Load().Recorder
+}
@@ -0,0 +1,99 @@
+// Package prom implements metrics.Recorder with Prometheus collectors.
+package prom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Recorder exports the package measurements as Prometheus metrics:
+//
+//	<namespace>_operation_duration_seconds{operation,index,result}  histogram
+//	<namespace>_search_hits{index}                                   histogram
+//	<namespace>_bulk_items_total{index,result}                       counter
+//	<namespace>_retries_total{kind}                                  counter
+//	<namespace>_circuit_state{state}                                 gauge, 1 for the current state
+type Recorder struct {
+	duration *prometheus.HistogramVec
+	hits     *prometheus.HistogramVec
+	bulk     *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	circuit  *prometheus.GaugeVec
+}
+
+// New creates a Recorder and registers its collectors with reg. namespace
+// defaults to "elasticsearch".
+func New(reg prometheus.Registerer, namespace string) (*Recorder, error) {
+	if namespace == "" {
+		namespace = "elasticsearch"
+	}
+
+	r := &Recorder{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of index and search operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "index", "result"}),
+		hits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "search_hits",
+			Help:      "Number of hits returned per search.",
+			Buckets:   []float64{0, 1, 5, 10, 20, 50, 100, 500},
+		}, []string{"index"}),
+		bulk: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bulk_items_total",
+			Help:      "Bulk items by outcome.",
+		}, []string{"index", "result"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Retried requests and bulk items.",
+		}, []string{"kind"}),
+		circuit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "circuit_state",
+			Help:      "Circuit breaker state, 1 for the current state.",
+		}, []string{"state"}),
+	}
+
+	for _, c := range []prometheus.Collector{r.duration, r.hits, r.bulk, r.retries, r.circuit} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Recorder) ObserveOperation(operation, index string, took time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	r.duration.WithLabelValues(operation, index, result).Observe(took.Seconds())
+}
+
+func (r *Recorder) ObserveHits(index string, hits int) {
+	r.hits.WithLabelValues(index).Observe(float64(hits))
+}
+
+func (r *Recorder) AddBulkItems(index string, succeeded, failed int) {
+	r.bulk.WithLabelValues(index, "ok").Add(float64(succeeded))
+	r.bulk.WithLabelValues(index, "failed").Add(float64(failed))
+}
+
+func (r *Recorder) IncRetry(kind string) {
+	r.retries.WithLabelValues(kind).Inc()
+}
+
+func (r *Recorder) SetCircuitState(state string) {
+	for _, s := range []string{"closed", "open", "half-open"} {
+		v := 0.0
+		if s == state {
+			v = 1
+		}
+		r.circuit.WithLabelValues(s).Set(v)
+	}
+}
@@ -9,18 +9,18 @@ type Car struct {
 	StockId        *int64      `json:"stock_id"`
 	StoreName      *string     `json:"store_name"`
 	BrandId        int64       `json:"brand_id"`
-	BrandName      *string     `json:"brand_name"`
+	BrandName      *string     `json:"brand_name" es:"keyword,sub=text:turkmen_folding"`
 	ModelId        int64       `json:"model_id"`
-	ModelName      *string     `json:"model_name"`
+	ModelName      *string     `json:"model_name" es:"keyword,sub=text:turkmen_folding"`
 	Year           int64       `json:"year"`
 	Price          int64       `json:"price"`
 	Color          string      `json:"color"`
 	Vin            *string     `json:"vin"`
-	Description    *string     `json:"description"`
+	Description    *string     `json:"description" es:"text,analyzer=turkmen_folding,sub=ru:russian"`
 	CityId         int64       `json:"city_id"`
-	CityNameTM     *string     `json:"city_name_tm"`
-	CityNameEN     *string     `json:"city_name_en"`
-	CityNameRU     *string     `json:"city_name_ru"`
+	CityNameTM     *string     `json:"city_name_tm" es:"keyword,sub=text:turkmen_folding"`
+	CityNameEN     *string     `json:"city_name_en" es:"keyword,sub=text:english"`
+	CityNameRU     *string     `json:"city_name_ru" es:"keyword,sub=text:russian"`
 	Name           *string     `json:"name"`
 	Mail           *string     `json:"mail"`
 	PhoneNumber    string      `json:"phone_number"`
@@ -35,9 +35,16 @@ type Car struct {
 	EngineCapacity float64     `json:"engine_capacity"`
 	EngineType     string      `json:"engine_type"`
 	BodyId         int64       `json:"body_id"`
-	BodyNameTM     *string     `json:"body_name_tm"`
-	BodyNameEN     *string     `json:"body_name_en"`
-	BodyNameRU     *string     `json:"body_name_ru"`
+	BodyNameTM     *string     `json:"body_name_tm" es:"keyword,sub=text:turkmen_folding"`
+	BodyNameEN     *string     `json:"body_name_en" es:"keyword,sub=text:english"`
+	BodyNameRU     *string     `json:"body_name_ru" es:"keyword,sub=text:russian"`
 	Transmission   string      `json:"transmission"`
 	DriveType      string      `json:"drive_type"`
 }
+
+// DocumentID returns the Elasticsearch document ID of the car.
+func (c Car) DocumentID() int64 { return c.ID }
+
+// DocumentVersion returns the external version of the car, its UpdatedAt
+// in milliseconds.
+func (c Car) DocumentVersion() int64 { return c.UpdatedAt.UnixMilli() }
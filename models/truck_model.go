@@ -9,13 +9,13 @@ type Truck struct {
 	StockId         *int64      `json:"stock_id"`
 	StoreName       *string     `json:"store_name"`
 	BodyId          int64       `json:"body_id"`
-	BodyNameTM      *string     `json:"body_name_tm"`
-	BodyNameEN      *string     `json:"body_name_en"`
-	BodyNameRU      *string     `json:"body_name_ru"`
+	BodyNameTM      *string     `json:"body_name_tm" es:"keyword,sub=text:turkmen_folding"`
+	BodyNameEN      *string     `json:"body_name_en" es:"keyword,sub=text:english"`
+	BodyNameRU      *string     `json:"body_name_ru" es:"keyword,sub=text:russian"`
 	BrandId         int64       `json:"brand_id"`
-	BrandName       *string     `json:"brand_name"`
+	BrandName       *string     `json:"brand_name" es:"keyword,sub=text:turkmen_folding"`
 	ModelId         int64       `json:"model_id"`
-	ModelName       *string     `json:"model_name"`
+	ModelName       *string     `json:"model_name" es:"keyword,sub=text:turkmen_folding"`
 	LoadCapacity    *string     `json:"load_capacity"`
 	Price           int64       `json:"price"`
 	BodyType        *string     `json:"body_type"`
@@ -42,11 +42,11 @@ type Truck struct {
 	BulldozerType   *string     `json:"bulldozer_type"`
 	Color           string      `json:"color"`
 	Vin             *string     `json:"vin"`
-	Description     *string     `json:"description"`
+	Description     *string     `json:"description" es:"text,analyzer=turkmen_folding,sub=ru:russian"`
 	CityId          int64       `json:"city_id"`
-	CityNameTM      *string     `json:"city_name_tm"`
-	CityNameEN      *string     `json:"city_name_en"`
-	CityNameRU      *string     `json:"city_name_ru"`
+	CityNameTM      *string     `json:"city_name_tm" es:"keyword,sub=text:turkmen_folding"`
+	CityNameEN      *string     `json:"city_name_en" es:"keyword,sub=text:english"`
+	CityNameRU      *string     `json:"city_name_ru" es:"keyword,sub=text:russian"`
 	Name            *string     `json:"name"`
 	Mail            *string     `json:"mail"`
 	PhoneNumber     string      `json:"phone_number"`
@@ -58,3 +58,10 @@ type Truck struct {
 	CreatedAt       time.Time   `json:"created_at"`
 	UpdatedAt       time.Time   `json:"updated_at"`
 }
+
+// DocumentID returns the Elasticsearch document ID of the truck.
+func (t Truck) DocumentID() int64 { return t.Id }
+
+// DocumentVersion returns the external version of the truck, its UpdatedAt
+// in milliseconds.
+func (t Truck) DocumentVersion() int64 { return t.UpdatedAt.UnixMilli() }